@@ -5,7 +5,10 @@ package godgets
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 )
 
@@ -33,33 +36,144 @@ Example usage:
 type AutoreloadingCertStore struct {
 	// Get(), Reload(), and ReloadIfChanged() are part of the public API:
 	AutoreloadingConfigStore[tls.Certificate]
+
+	// ExpiryWarning, if nonzero, is the threshold for warning about an upcoming
+	// expiration: whenever a certificate is loaded, and periodically thereafter
+	// (every CheckInterval, or hourly if that is zero), if the current
+	// certificate expires within ExpiryWarning, ExpiryCallback is invoked.
+	ExpiryWarning time.Duration
+	// ExpiryCallback receives expiration warnings; if it is nil, they are logged.
+	ExpiryCallback func(leaf *x509.Certificate, remaining time.Duration)
+	// RootCAs, if set, is used to verify the certificate chain (using any
+	// intermediates from the certificate file) before a certificate is
+	// swapped in.
+	RootCAs *x509.CertPool
+	// KeyUsages are the extended key usages that the chain must be valid for,
+	// if RootCAs is set; a nil value means x509.ExtKeyUsageServerAuth. For
+	// example, a client certificate requires x509.ExtKeyUsageClientAuth.
+	KeyUsages []x509.ExtKeyUsage
+	// ExpectedHostnames, if set, is a list of names that the certificate
+	// must be valid for, or it will not be swapped in.
+	ExpectedHostnames []string
+	// MismatchRetryInterval is the delay before retrying a load that failed
+	// because the certificate and key didn't match (see Initialize); a zero
+	// value means a default of one second.
+	MismatchRetryInterval time.Duration
+	// Passphrase, if set, returns the passphrase for an encrypted private key
+	// or a PKCS #12 bundle; it is called on every load (see PassphraseFile).
+	Passphrase func() ([]byte, error)
+	// OCSPStapleFile, if set, is a file containing a DER-encoded OCSP response
+	// for the certificate, to be stapled to it; alternatively, OCSPFetcher
+	// fetches the response (see HTTPOCSPFetcher). The response is validated
+	// against the issuer (the second certificate in the chain), and refreshed
+	// halfway through its validity period; it is reloaded together with the
+	// certificate, so the served certificate and staple always match.
+	OCSPStapleFile string
+	OCSPFetcher    OCSPFetcher
+
+	expiryMutex sync.Mutex
+	expiryTimer ClockTimer
+
+	ocspMutex   sync.Mutex
+	ocspTimer   ClockTimer
+	ocspStopped bool
 }
 
+// Initialize loads the PEM-encoded certificate chain from `certfile` and the
+// private key from `keyfile`, which may be empty if `certfile` also contains
+// the key. The key may be encrypted (see Passphrase).
 func (a *AutoreloadingCertStore) Initialize(certfile, keyfile string, checkInterval time.Duration) error {
+	if keyfile == "" {
+		keyfile = certfile
+	}
 	// stat(2) on the certificate, not the key (the certificate can change
 	// while the key remains the same, but not vice versa). there is a race
 	// condition where both files are changed and we attempt to load the new
-	// certificate and the old key, but this should be a transient reload failure
-	// and we should get a correct view on the next reload attempt
-	a.Path = certfile
+	// certificate and the old key; we detect this explicitly (ErrCertKeyMismatch)
+	// and retry after MismatchRetryInterval, instead of a full CheckInterval
+	return a.initialize(certfile, func() (tls.Certificate, error) {
+		return a.loadKeyPair(certfile, keyfile)
+	}, checkInterval)
+}
+
+func (a *AutoreloadingCertStore) initialize(path string, load func() (tls.Certificate, error), checkInterval time.Duration) error {
+	a.Path = path
 	a.LoadCallback = func(_ string) (*tls.Certificate, error) {
-		cert, err := tls.LoadX509KeyPair(certfile, keyfile)
+		cert, err := load()
+		if err == nil {
+			err = a.checkValidity(&cert)
+		}
+		if err == nil {
+			err = a.verifyCertificate(&cert)
+		}
 		if err != nil {
 			log.Printf("Failed to reload TLS certificate: %v\n", err)
+			// the result of a failed initial load is stored, so don't
+			// return a rejected certificate:
+			return new(tls.Certificate), err
 		}
-		return &cert, err
+		a.staple(&cert)
+		return &cert, nil
 	}
 	a.CheckInterval = checkInterval
 	_, err := a.AutoreloadingConfigStore.Initialize()
+	a.startExpiryChecks()
 	return err
 }
 
+// Stop prevents the certificate from autoreloading further.
+func (a *AutoreloadingCertStore) Stop() {
+	a.stopExpiryChecks()
+	a.stopStapleRefresh()
+	a.AutoreloadingConfigStore.Stop()
+}
+
+// loadKeyPair is tls.LoadX509KeyPair, but reading from the store's FS,
+// and decrypting the key if necessary
+func (a *AutoreloadingCertStore) loadKeyPair(certfile, keyfile string) (cert tls.Certificate, err error) {
+	certPEMBlock, err := a.fs().ReadFile(certfile)
+	if err != nil {
+		return
+	}
+	keyPEMBlock, err := a.fs().ReadFile(keyfile)
+	if err != nil {
+		return
+	}
+	if keyPEMBlock, err = a.decryptKeyPEM(keyPEMBlock); err != nil {
+		return cert, fmt.Errorf("%s: %w", keyfile, err)
+	}
+	cert, err = tls.X509KeyPair(certPEMBlock, keyPEMBlock)
+	if err != nil && keyPairMismatch(certPEMBlock, keyPEMBlock) {
+		err = RetryAfter(fmt.Errorf("%w: %s and %s", ErrCertKeyMismatch, certfile, keyfile), a.mismatchRetryInterval())
+	}
+	return
+}
+
 // GetCertificate is a callback suitable for use as (*tls.Config).GetCertificate:
 // it retrieves the latest available certificate from the store.
 func (a *AutoreloadingCertStore) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	return a.Get(), nil
 }
 
+// GetClientCertificate is a callback suitable for use as
+// (*tls.Config).GetClientCertificate, for presenting the certificate
+// to servers that request client certificates.
+func (a *AutoreloadingCertStore) GetClientCertificate(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if cert := a.Get(); cert != nil {
+		return cert, nil
+	}
+	// an empty certificate means that no certificate is sent
+	return new(tls.Certificate), nil
+}
+
+// ClientTLSConfig returns a *tls.Config with a GetClientCertificate member
+// that uses the store. Callers may wish to populate other fields.
+func (a *AutoreloadingCertStore) ClientTLSConfig() *tls.Config {
+	return &tls.Config{
+		GetClientCertificate: a.GetClientCertificate,
+	}
+}
+
 // TLSConfig returns a *tls.Config with a GetCertificate member that uses the store.
 // Callers may wish to populate other fields.
 func (a *AutoreloadingCertStore) TLSConfig() *tls.Config {
@@ -0,0 +1,54 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"crypto/tls"
+)
+
+/*
+ClientCertificates is a set of client certificate stores, for a client
+that presents different certificates to different servers. During each
+handshake, it selects the first certificate that is acceptable to the server
+(i.e., that is issued by one of the server's AcceptableCAs, and supports one of
+its signature schemes); if none is, no certificate is sent, as crypto/tls does
+for (*tls.Config).Certificates.
+
+Example usage:
+
+	internal := new(godgets.AutoreloadingCertStore)
+	internal.Initialize("/etc/ssl/internal-client.crt", "/etc/ssl/internal-client.key", time.Minute)
+	partner := new(godgets.AutoreloadingCertStore)
+	partner.Initialize("/etc/ssl/partner-client.crt", "/etc/ssl/partner-client.key", time.Minute)
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: godgets.ClientCertificates{internal, partner}.TLSConfig(),
+		},
+	}
+*/
+type ClientCertificates []*AutoreloadingCertStore
+
+// GetClientCertificate is a callback suitable for use as
+// (*tls.Config).GetClientCertificate.
+func (c ClientCertificates) GetClientCertificate(cri *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	for _, store := range c {
+		cert := store.Get()
+		if cert == nil || len(cert.Certificate) == 0 {
+			continue
+		}
+		if cri.SupportsCertificate(cert) == nil {
+			return cert, nil
+		}
+	}
+	// an empty certificate means that no certificate is sent
+	return new(tls.Certificate), nil
+}
+
+// TLSConfig returns a *tls.Config with a GetClientCertificate member that
+// uses the stores. Callers may wish to populate other fields.
+func (c ClientCertificates) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetClientCertificate: c.GetClientCertificate,
+	}
+}
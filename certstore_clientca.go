@@ -0,0 +1,258 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrNoClientCAs        = errors.New("no CA certificates found in client CA file")
+	ErrCertificateRevoked = errors.New("certificate has been revoked")
+)
+
+// ClientCAs is a loaded client CA bundle, together with any certificate
+// revocation lists for the CAs.
+type ClientCAs struct {
+	Pool  *x509.CertPool
+	Certs []*x509.Certificate
+	CRLs  []*x509.RevocationList
+
+	// maps the raw subject of an issuer to the serials (as strings) it revoked
+	revoked map[string]map[string]struct{}
+}
+
+// IsRevoked determines whether `cert` was revoked by one of the CRLs.
+func (c *ClientCAs) IsRevoked(cert *x509.Certificate) bool {
+	_, revoked := c.revoked[string(cert.RawIssuer)][cert.SerialNumber.String()]
+	return revoked
+}
+
+/*
+AutoreloadingClientCAStore is a store for the client CA bundle of a server
+that requires client certificates (mutual TLS), together with optional
+certificate revocation lists (CRLs). It reloads when the bundle or any of
+the CRLs change, and uses GetConfigForClient so that new CAs and CRLs take
+effect for new connections without restarting listeners.
+
+Example usage:
+
+	certs := new(godgets.AutoreloadingCertStore)
+	certs.Initialize("/etc/ssl/server.crt", "/etc/ssl/server.key", time.Minute)
+	clientCAs := new(godgets.AutoreloadingClientCAStore)
+	if err := clientCAs.Initialize("/etc/ssl/client-ca.pem", []string{"/etc/ssl/crls/"}, time.Minute); err != nil {
+		log.Fatal(err)
+	}
+	listener, err := tls.Listen("tcp", ":443", clientCAs.TLSConfig(certs.TLSConfig()))
+*/
+type AutoreloadingClientCAStore struct {
+	AutoreloadingMultiConfigStore[ClientCAs]
+	// ClientAuth is the client authentication policy for the server;
+	// a zero value means tls.RequireAndVerifyClientCert.
+	ClientAuth tls.ClientAuthType
+}
+
+// Initialize loads the CA bundle `caFile` (a file of PEM-encoded certificates)
+// and the CRLs in `crlPaths`, which may be files, directories, or glob patterns
+// (as for AutoreloadingMultiConfigStore), and contain PEM- or DER-encoded CRLs.
+// Each CRL must be signed by one of the CAs in the bundle.
+func (a *AutoreloadingClientCAStore) Initialize(caFile string, crlPaths []string, checkInterval time.Duration) (err error) {
+	// the CA file is always the first member of the set:
+	a.Paths = append([]string{caFile}, crlPaths...)
+	a.CheckInterval = checkInterval
+	a.LoadCallback = func(files []string) (*ClientCAs, error) {
+		cas, err := loadClientCAs(files[0], files[1:])
+		if err != nil {
+			log.Printf("Failed to reload client CAs: %v\n", err)
+		}
+		return cas, err
+	}
+	_, err = a.AutoreloadingMultiConfigStore.Initialize()
+	return
+}
+
+func loadClientCAs(caFile string, crlFiles []string) (*ClientCAs, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	result := &ClientCAs{
+		Pool:    x509.NewCertPool(),
+		revoked: make(map[string]map[string]struct{}),
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", caFile, err)
+		}
+		result.Pool.AddCert(cert)
+		result.Certs = append(result.Certs, cert)
+	}
+	if len(result.Certs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoClientCAs, caFile)
+	}
+
+	for _, crlFile := range crlFiles {
+		crls, err := readCRLs(crlFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", crlFile, err)
+		}
+		for _, crl := range crls {
+			if err := result.addCRL(crl); err != nil {
+				return nil, fmt.Errorf("%s: %w", crlFile, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+func readCRLs(path string) (crls []*x509.RevocationList, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		crl, err := x509.ParseRevocationList(data)
+		if err != nil {
+			return nil, err
+		}
+		return []*x509.RevocationList{crl}, nil
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "X509 CRL" {
+			continue
+		}
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		crls = append(crls, crl)
+	}
+	return
+}
+
+func (c *ClientCAs) addCRL(crl *x509.RevocationList) error {
+	var issuer *x509.Certificate
+	for _, cert := range c.Certs {
+		if bytes.Equal(cert.RawSubject, crl.RawIssuer) && crl.CheckSignatureFrom(cert) == nil {
+			issuer = cert
+			break
+		}
+	}
+	if issuer == nil {
+		return errors.New("CRL is not signed by any of the client CAs")
+	}
+	serials := c.revoked[string(issuer.RawSubject)]
+	if serials == nil {
+		serials = make(map[string]struct{})
+		c.revoked[string(issuer.RawSubject)] = serials
+	}
+	for _, revoked := range crl.RevokedCertificates {
+		serials[revoked.SerialNumber.String()] = struct{}{}
+	}
+	c.CRLs = append(c.CRLs, crl)
+	return nil
+}
+
+// verifyChains is suitable for use as (*tls.Config).VerifyPeerCertificate:
+// it accepts the connection if any of the verified chains contains no
+// revoked certificates.
+func (c *ClientCAs) verifyChains(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+	if len(verifiedChains) == 0 {
+		// no client certificate was presented, or it was not verified
+		// (depending on ClientAuth); there is nothing to check
+		return nil
+	}
+	for _, chain := range verifiedChains {
+		clean := true
+		for _, cert := range chain {
+			if c.IsRevoked(cert) {
+				clean = false
+				break
+			}
+		}
+		if clean {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrCertificateRevoked, verifiedChains[0][0].Subject)
+}
+
+// clientCAConfig is a per-connection config, built from a load of the CAs.
+type clientCAConfig struct {
+	cas    *ClientCAs
+	config *tls.Config
+}
+
+// TLSConfig returns a *tls.Config based on `base` (which may be nil), whose
+// GetConfigForClient member returns a copy of `base` using the current client
+// CAs and CRLs. For example, `base` may be the result of
+// (*AutoreloadingCertStore).TLSConfig(). Callers should not modify `base`
+// after calling TLSConfig.
+func (a *AutoreloadingClientCAStore) TLSConfig(base *tls.Config) *tls.Config {
+	if base == nil {
+		base = new(tls.Config)
+	}
+	clientAuth := a.ClientAuth
+	if clientAuth == tls.NoClientCert {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+	build := func(cas *ClientCAs) *tls.Config {
+		config := base.Clone()
+		config.GetConfigForClient = nil
+		config.ClientAuth = clientAuth
+		config.ClientCAs = cas.Pool
+		config.VerifyPeerCertificate = cas.verifyChains
+		if verify := base.VerifyPeerCertificate; verify != nil {
+			config.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+				if err := cas.verifyChains(rawCerts, verifiedChains); err != nil {
+					return err
+				}
+				return verify(rawCerts, verifiedChains)
+			}
+		}
+		return config
+	}
+	// build the per-connection config once per load of the CAs; this is cached
+	// here rather than with Derive, which would register a derivation with
+	// the store on every call to TLSConfig, outliving the returned config
+	var cache atomic.Pointer[clientCAConfig]
+	result := base.Clone()
+	result.ClientAuth = clientAuth
+	result.GetConfigForClient = func(_ *tls.ClientHelloInfo) (*tls.Config, error) {
+		cas := a.Get()
+		if cas == nil {
+			return nil, errors.New("no client CAs loaded")
+		}
+		if cached := cache.Load(); cached != nil && cached.cas == cas {
+			return cached.config, nil
+		}
+		config := build(cas)
+		cache.Store(&clientCAConfig{cas: cas, config: config})
+		return config, nil
+	}
+	return result
+}
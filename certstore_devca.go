@@ -0,0 +1,415 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+DevCA is a local certificate authority for development and test environments,
+which issues certificates without network access. Its certificates should not
+be used in production.
+
+Example usage:
+
+	ca, err := godgets.LoadOrCreateDevCA("dev/ca.crt", "dev/ca.key", "Dev CA")
+	if err != nil {
+		log.Fatal(err)
+	}
+	renewer := godgets.DevCertRenewer{
+		CA:       ca,
+		CertFile: "dev/server.crt",
+		KeyFile:  "dev/server.key",
+		Hosts:    []string{"localhost", "127.0.0.1", "::1"},
+	}
+	if err := renewer.Initialize(); err != nil {
+		log.Fatal(err)
+	}
+	certStore.Initialize("dev/server.crt", "dev/server.key", time.Minute)
+*/
+type DevCA struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// NewDevCA generates a new CA, valid for `validity`.
+func NewDevCA(name string, validity time.Duration) (*DevCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &DevCA{Cert: cert, Key: key}, nil
+}
+
+// LoadOrCreateDevCA loads a CA from `certfile` and `keyfile`; if they do not
+// exist, it generates a new CA named `name` (valid for ten years) and writes it
+// to them.
+func LoadOrCreateDevCA(certfile, keyfile, name string) (*DevCA, error) {
+	certPEM, certErr := os.ReadFile(certfile)
+	keyPEM, keyErr := os.ReadFile(keyfile)
+	if errors.Is(certErr, os.ErrNotExist) && errors.Is(keyErr, os.ErrNotExist) {
+		ca, err := NewDevCA(name, 10*365*24*time.Hour)
+		if err != nil {
+			return nil, err
+		}
+		return ca, ca.WriteFiles(certfile, keyfile)
+	} else if certErr != nil {
+		return nil, certErr
+	} else if keyErr != nil {
+		return nil, keyErr
+	}
+	cert, key, err := parseDevCertificate(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("%s is not a CA certificate", certfile)
+	}
+	return &DevCA{Cert: cert, Key: key}, nil
+}
+
+// WriteFiles atomically writes the CA certificate and key.
+func (ca *DevCA) WriteFiles(certfile, keyfile string) error {
+	return writeCertificateFiles(certfile, keyfile, ca.Cert.Raw, ca.Key)
+}
+
+// Issue issues a new leaf certificate for `hosts` (DNS names or IP addresses),
+// valid for `validity`, returning the PEM-encoded certificate and key.
+func (ca *DevCA) Issue(hosts []string, validity time.Duration) (certPEM, keyPEM []byte, err error) {
+	return ca.issue(time.Now(), hosts, validity)
+}
+
+func (ca *DevCA) issue(now time.Time, hosts []string, validity time.Duration) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+	template, err := leafTemplate(now, hosts, validity)
+	if err != nil {
+		return
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, key.Public(), ca.Key)
+	if err != nil {
+		return
+	}
+	return encodeCertificate(der, key)
+}
+
+// WriteLeaf issues a new leaf certificate (see Issue) and writes it atomically;
+// the key is written first, so a concurrent reader sees at most a transient
+// mismatch (see ErrCertKeyMismatch).
+func (ca *DevCA) WriteLeaf(certfile, keyfile string, hosts []string, validity time.Duration) error {
+	return ca.writeLeaf(time.Now(), certfile, keyfile, hosts, validity)
+}
+
+func (ca *DevCA) writeLeaf(now time.Time, certfile, keyfile string, hosts []string, validity time.Duration) error {
+	certPEM, keyPEM, err := ca.issue(now, hosts, validity)
+	if err != nil {
+		return err
+	}
+	return writeCertificatePEM(certfile, keyfile, certPEM, keyPEM)
+}
+
+// WriteSelfSignedCert generates a self-signed certificate for `hosts`
+// (DNS names or IP addresses), valid for `validity`, and writes it atomically.
+func WriteSelfSignedCert(certfile, keyfile string, hosts []string, validity time.Duration) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	template, err := leafTemplate(time.Now(), hosts, validity)
+	if err != nil {
+		return err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return err
+	}
+	return writeCertificateFiles(certfile, keyfile, der, key)
+}
+
+/*
+DevCertRenewer maintains a leaf certificate issued by a DevCA: it issues the
+certificate if it is missing, invalid, or for the wrong hosts, and reissues it
+before it expires, so that an AutoreloadingCertStore for the files picks up
+the new certificate.
+*/
+type DevCertRenewer struct {
+	CA                *DevCA
+	CertFile, KeyFile string
+	Hosts             []string
+	// Validity is the validity period of issued certificates;
+	// a zero value means a default of 30 days.
+	Validity time.Duration
+	// RenewBefore is how long before expiration the certificate is
+	// reissued; a zero value means a third of Validity.
+	RenewBefore time.Duration
+	Clock       Clock
+
+	mutex   sync.Mutex
+	timer   ClockTimer
+	stopped bool
+}
+
+// Initialize ensures that a valid certificate exists, and schedules its renewal.
+func (r *DevCertRenewer) Initialize() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.stopped = false
+	return r.renew()
+}
+
+// Stop stops further renewals.
+func (r *DevCertRenewer) Stop() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.stopped = true
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
+
+// renew checks the certificate, reissuing it if necessary, and schedules
+// the next check; the caller must hold the mutex.
+func (r *DevCertRenewer) renew() (err error) {
+	now := r.clock().Now()
+	notAfter, ok := r.currentExpiry(now)
+	if !ok {
+		if err = r.CA.writeLeaf(now, r.CertFile, r.KeyFile, r.Hosts, r.validity()); err == nil {
+			notAfter = now.Add(r.validity())
+		}
+	}
+	delay := notAfter.Sub(now) - r.renewBefore()
+	if err != nil {
+		log.Printf("Failed to issue development certificate: %v\n", err)
+		delay = time.Minute
+	} else if delay < time.Minute {
+		// RenewBefore is too large relative to Validity; don't reissue continuously
+		delay = time.Minute
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.timer = r.clock().AfterFunc(delay, r.periodicRenew)
+	return
+}
+
+func (r *DevCertRenewer) periodicRenew() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.stopped {
+		r.renew()
+	}
+}
+
+// currentExpiry returns the expiration time of the existing certificate,
+// if it is usable and not due for renewal.
+func (r *DevCertRenewer) currentExpiry(now time.Time) (notAfter time.Time, ok bool) {
+	certPEM, err := os.ReadFile(r.CertFile)
+	if err != nil {
+		return
+	}
+	keyPEM, err := os.ReadFile(r.KeyFile)
+	if err != nil {
+		return
+	}
+	cert, _, err := parseDevCertificate(certPEM, keyPEM)
+	if err != nil || cert.CheckSignatureFrom(r.CA.Cert) != nil {
+		return
+	}
+	if !equalStringSets(certificateHosts(cert), r.Hosts) {
+		return
+	}
+	if now.Before(cert.NotBefore) || !now.Add(r.renewBefore()).Before(cert.NotAfter) {
+		return
+	}
+	return cert.NotAfter, true
+}
+
+func (r *DevCertRenewer) validity() time.Duration {
+	if r.Validity != 0 {
+		return r.Validity
+	}
+	return 30 * 24 * time.Hour
+}
+
+func (r *DevCertRenewer) renewBefore() time.Duration {
+	if r.RenewBefore != 0 {
+		return r.RenewBefore
+	}
+	return r.validity() / 3
+}
+
+func (r *DevCertRenewer) clock() Clock {
+	if r.Clock != nil {
+		return r.Clock
+	}
+	return realClock{}
+}
+
+func leafTemplate(now time.Time, hosts []string, validity time.Duration) (*x509.Certificate, error) {
+	if len(hosts) == 0 {
+		return nil, errors.New("no hosts specified for certificate")
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	return template, nil
+}
+
+// certificateHosts returns the DNS names and IP addresses of a certificate.
+func certificateHosts(cert *x509.Certificate) (hosts []string) {
+	hosts = append(hosts, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		hosts = append(hosts, ip.String())
+	}
+	return
+}
+
+func equalStringSets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func encodeCertificate(der []byte, key crypto.Signer) (certPEM, keyPEM []byte, err error) {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return
+}
+
+func parseDevCertificate(certPEM, keyPEM []byte) (cert *x509.Certificate, key crypto.Signer, err error) {
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, nil, errors.New("invalid PEM data")
+	}
+	if cert, err = x509.ParseCertificate(certBlock.Bytes); err != nil {
+		return
+	}
+	if key, err = parsePrivateKey(keyBlock.Bytes); err != nil {
+		return
+	}
+	if !publicKeysEqual(cert.PublicKey, key.Public()) {
+		return nil, nil, ErrCertKeyMismatch
+	}
+	return
+}
+
+func writeCertificateFiles(certfile, keyfile string, der []byte, key crypto.Signer) error {
+	certPEM, keyPEM, err := encodeCertificate(der, key)
+	if err != nil {
+		return err
+	}
+	return writeCertificatePEM(certfile, keyfile, certPEM, keyPEM)
+}
+
+func writeCertificatePEM(certfile, keyfile string, certPEM, keyPEM []byte) error {
+	if err := writeFileAtomic(keyfile, keyPEM, 0600); err != nil {
+		return err
+	}
+	return writeFileAtomic(certfile, certPEM, 0644)
+}
+
+// writeFileAtomic writes a file by renaming a temporary file into place,
+// so that readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	return
+}
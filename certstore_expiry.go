@@ -0,0 +1,114 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	ErrCertificateExpired     = errors.New("certificate has expired")
+	ErrCertificateNotYetValid = errors.New("certificate is not yet valid")
+)
+
+// checkValidity populates the Leaf of a newly loaded certificate, and rejects
+// it if it is outside its validity period; otherwise, it checks whether to
+// warn about its expiration.
+func (a *AutoreloadingCertStore) checkValidity(cert *tls.Certificate) (err error) {
+	leaf, err := certificateLeaf(cert)
+	if err != nil {
+		return
+	}
+	cert.Leaf = leaf
+	now := a.clock().Now()
+	if now.After(leaf.NotAfter) {
+		return fmt.Errorf("%w: %s expired at %v", ErrCertificateExpired, leaf.Subject, leaf.NotAfter)
+	} else if now.Before(leaf.NotBefore) {
+		return fmt.Errorf("%w: %s is valid starting at %v", ErrCertificateNotYetValid, leaf.Subject, leaf.NotBefore)
+	}
+	a.warnIfExpiring(leaf)
+	return nil
+}
+
+// NotAfter returns the expiration time of the current certificate, or the
+// zero time if there is no current certificate.
+func (a *AutoreloadingCertStore) NotAfter() (notAfter time.Time) {
+	if cert := a.Get(); cert != nil {
+		if leaf, err := certificateLeaf(cert); err == nil {
+			notAfter = leaf.NotAfter
+		}
+	}
+	return
+}
+
+// TimeToExpiry returns the time remaining until the current certificate
+// expires (which is negative if it has already expired), or 0 if there is
+// no current certificate.
+func (a *AutoreloadingCertStore) TimeToExpiry() time.Duration {
+	notAfter := a.NotAfter()
+	if notAfter.IsZero() {
+		return 0
+	}
+	return notAfter.Sub(a.clock().Now())
+}
+
+func (a *AutoreloadingCertStore) warnIfExpiring(leaf *x509.Certificate) {
+	if a.ExpiryWarning == 0 {
+		return
+	}
+	remaining := leaf.NotAfter.Sub(a.clock().Now())
+	if remaining >= a.ExpiryWarning {
+		return
+	}
+	if a.ExpiryCallback != nil {
+		a.ExpiryCallback(leaf, remaining)
+	} else {
+		log.Printf("TLS certificate for %s expires in %v (at %v)\n", leaf.Subject, remaining, leaf.NotAfter)
+	}
+}
+
+func (a *AutoreloadingCertStore) startExpiryChecks() {
+	if a.ExpiryWarning == 0 {
+		return
+	}
+	a.expiryMutex.Lock()
+	defer a.expiryMutex.Unlock()
+	a.expiryTimer = a.clock().AfterFunc(a.expiryCheckInterval(), a.periodicExpiryCheck)
+}
+
+func (a *AutoreloadingCertStore) stopExpiryChecks() {
+	a.expiryMutex.Lock()
+	defer a.expiryMutex.Unlock()
+	if a.expiryTimer != nil {
+		a.expiryTimer.Stop()
+		a.expiryTimer = nil
+	}
+}
+
+func (a *AutoreloadingCertStore) expiryCheckInterval() time.Duration {
+	if a.CheckInterval != 0 {
+		return a.CheckInterval
+	}
+	return time.Hour
+}
+
+func (a *AutoreloadingCertStore) periodicExpiryCheck() {
+	if cert := a.Get(); cert != nil {
+		if leaf, err := certificateLeaf(cert); err == nil {
+			a.warnIfExpiring(leaf)
+		}
+	}
+
+	a.expiryMutex.Lock()
+	defer a.expiryMutex.Unlock()
+	// if we were stopped, the timer was cleared:
+	if a.expiryTimer != nil {
+		a.expiryTimer.Reset(a.expiryCheckInterval())
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/pkcs12"
+)
+
+var (
+	ErrPassphraseRequired  = errors.New("private key is encrypted, but no passphrase was configured")
+	ErrIncorrectPassphrase = errors.New("incorrect passphrase for private key")
+)
+
+// PKCS12Decoder decodes a PKCS #12 bundle, returning the private key, the
+// leaf certificate, and any additional (intermediate) certificates. Its
+// signature matches DecodeChain from software.sslmate.com/src/go-pkcs12,
+// which supports modern, AES-encrypted bundles (unlike DecodePKCS12Chain).
+type PKCS12Decoder func(pfxData []byte, password string) (privateKey any, certificate *x509.Certificate, caCerts []*x509.Certificate, err error)
+
+// PassphraseFile returns a function suitable for use as
+// (*AutoreloadingCertStore).Passphrase, which reads the passphrase from
+// `path` on every load (ignoring a trailing newline), so that it can be
+// rotated together with the key.
+func PassphraseFile(path string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimRight(data, "\r\n"), nil
+	}
+}
+
+// InitializePKCS12 is like Initialize, but loads the certificate chain and
+// key from a PKCS #12 bundle (with the password, if any, from Passphrase),
+// using `decode`; if it is nil, DecodePKCS12Chain is used.
+func (a *AutoreloadingCertStore) InitializePKCS12(bundle string, decode PKCS12Decoder, checkInterval time.Duration) error {
+	return a.initialize(bundle, func() (tls.Certificate, error) {
+		return a.loadPKCS12(bundle, decode)
+	}, checkInterval)
+}
+
+func (a *AutoreloadingCertStore) loadPKCS12(bundle string, decode PKCS12Decoder) (cert tls.Certificate, err error) {
+	if decode == nil {
+		decode = DecodePKCS12Chain
+	}
+	data, err := a.fs().ReadFile(bundle)
+	if err != nil {
+		return
+	}
+	var password []byte
+	if a.Passphrase != nil {
+		if password, err = a.Passphrase(); err != nil {
+			return
+		}
+	}
+	key, leaf, caCerts, err := decode(data, string(password))
+	if err != nil {
+		return cert, fmt.Errorf("%s: %w", bundle, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok || leaf == nil || !publicKeysEqual(leaf.PublicKey, signer.Public()) {
+		return cert, fmt.Errorf("%w: %s", ErrCertKeyMismatch, bundle)
+	}
+	cert.PrivateKey = key
+	cert.Leaf = leaf
+	cert.Certificate = append(cert.Certificate, leaf.Raw)
+	for _, caCert := range caCerts {
+		cert.Certificate = append(cert.Certificate, caCert.Raw)
+	}
+	return
+}
+
+// DecodePKCS12Chain is a PKCS12Decoder using golang.org/x/crypto/pkcs12,
+// which only supports the legacy encryption algorithms (3DES and RC2, as
+// produced by `openssl pkcs12 -export -legacy`); the leaf certificate is the
+// one matching the private key, and the others are returned as caCerts.
+func DecodePKCS12Chain(pfxData []byte, password string) (privateKey any, certificate *x509.Certificate, caCerts []*x509.Certificate, err error) {
+	blocks, err := pkcs12.ToPEM(pfxData, password)
+	if err != nil {
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			err = ErrIncorrectPassphrase
+		}
+		return
+	}
+	var signer crypto.Signer
+	var certs []*x509.Certificate
+	for _, block := range blocks {
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			certs = append(certs, cert)
+		case "PRIVATE KEY":
+			if signer != nil {
+				return nil, nil, nil, errors.New("PKCS #12 bundle contains multiple private keys")
+			}
+			// ToPEM encodes keys as PKCS #1 (RSA) or SEC 1 (ECDSA), not PKCS #8:
+			if rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(block.Bytes); rsaErr == nil {
+				signer = rsaKey
+			} else if ecKey, ecErr := x509.ParseECPrivateKey(block.Bytes); ecErr == nil {
+				signer = ecKey
+			} else {
+				return nil, nil, nil, errors.New("failed to parse private key in PKCS #12 bundle")
+			}
+		}
+	}
+	if signer == nil {
+		return nil, nil, nil, errors.New("PKCS #12 bundle contains no private key")
+	}
+	for _, cert := range certs {
+		if certificate == nil && publicKeysEqual(cert.PublicKey, signer.Public()) {
+			certificate = cert
+		} else {
+			caCerts = append(caCerts, cert)
+		}
+	}
+	return signer, certificate, caCerts, nil
+}
+
+// decryptKeyPEM replaces any encrypted private keys in keyPEMBlock
+// with their decrypted versions, using Passphrase. It supports PKCS #8
+// (PBES2, as produced by modern OpenSSL) and legacy (RFC 1423) encryption.
+func (a *AutoreloadingCertStore) decryptKeyPEM(keyPEMBlock []byte) ([]byte, error) {
+	if !bytes.Contains(keyPEMBlock, []byte("ENCRYPTED")) {
+		return keyPEMBlock, nil
+	}
+	var passphrase []byte
+	var result bytes.Buffer
+	for {
+		var block *pem.Block
+		block, keyPEMBlock = pem.Decode(keyPEMBlock)
+		if block == nil {
+			break
+		}
+		// legacy PEM encryption is insecure (and deprecated), but still deployed:
+		legacy := x509.IsEncryptedPEMBlock(block)
+		if legacy || block.Type == "ENCRYPTED PRIVATE KEY" {
+			if passphrase == nil {
+				if a.Passphrase == nil {
+					return nil, ErrPassphraseRequired
+				}
+				var err error
+				if passphrase, err = a.Passphrase(); err != nil {
+					return nil, err
+				}
+			}
+			if legacy {
+				der, err := x509.DecryptPEMBlock(block, passphrase)
+				if err != nil {
+					return nil, ErrIncorrectPassphrase
+				}
+				block = &pem.Block{Type: block.Type, Bytes: der}
+			} else {
+				der, err := decryptPKCS8(block.Bytes, passphrase)
+				if err != nil {
+					return nil, err
+				}
+				block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+			}
+		}
+		pem.Encode(&result, block)
+	}
+	return result.Bytes(), nil
+}
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// RFC 5958 and RFC 8018:
+type encryptedPrivateKeyInfo struct {
+	EncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// bound on the PBKDF2 iteration count of an encrypted key, since it's
+// read from the key file (OpenSSL's default is 2048):
+const maxPBKDF2Iterations = 1 << 22
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// decryptPKCS8 decrypts a DER-encoded PKCS #8 EncryptedPrivateKeyInfo,
+// encrypted with PBES2 (PBKDF2 and AES-CBC).
+func decryptPKCS8(der, passphrase []byte) (result []byte, err error) {
+	var info encryptedPrivateKeyInfo
+	if _, err = asn1.Unmarshal(der, &info); err != nil {
+		return
+	}
+	if !info.EncryptionAlgorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported key encryption algorithm %v", info.EncryptionAlgorithm.Algorithm)
+	}
+	var params pbes2Params
+	if _, err = asn1.Unmarshal(info.EncryptionAlgorithm.Parameters.FullBytes, &params); err != nil {
+		return
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function %v", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdfParams pbkdf2Params
+	if _, err = asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return
+	}
+	var prf func() hash.Hash
+	switch algorithm := kdfParams.PRF.Algorithm; {
+	case len(algorithm) == 0, algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	case algorithm.Equal(oidHMACWithSHA512):
+		prf = sha512.New
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 function %v", algorithm)
+	}
+	var keyLen int
+	switch algorithm := params.EncryptionScheme.Algorithm; {
+	case algorithm.Equal(oidAES128CBC):
+		keyLen = 16
+	case algorithm.Equal(oidAES192CBC):
+		keyLen = 24
+	case algorithm.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf("unsupported key encryption cipher %v", algorithm)
+	}
+	if kdfParams.IterationCount < 1 || kdfParams.IterationCount > maxPBKDF2Iterations {
+		return nil, fmt.Errorf("unsupported PBKDF2 iteration count %d", kdfParams.IterationCount)
+	}
+	var iv []byte
+	if _, err = asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return
+	}
+	key := pbkdf2.Key(passphrase, kdfParams.Salt, kdfParams.IterationCount, keyLen, prf)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+	data := info.EncryptedData
+	if len(iv) != block.BlockSize() || len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, errors.New("invalid encrypted private key")
+	}
+	result = make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(result, data)
+	// remove and validate the PKCS #7 padding; invalid padding almost always
+	// indicates an incorrect passphrase
+	padding := int(result[len(result)-1])
+	if padding == 0 || padding > block.BlockSize() {
+		return nil, ErrIncorrectPassphrase
+	}
+	for _, b := range result[len(result)-padding:] {
+		if int(b) != padding {
+			return nil, ErrIncorrectPassphrase
+		}
+	}
+	return result[:len(result)-padding], nil
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// if no valid staple could be obtained, retry after this long:
+	ocspRetryInterval = time.Minute
+	// bound on the size of an OCSP response:
+	maxOCSPResponseSize = 1 << 20
+)
+
+// OCSPFetcher fetches a DER-encoded OCSP response for `leaf`, which was
+// issued by `issuer`; see HTTPOCSPFetcher.
+type OCSPFetcher func(ctx context.Context, leaf, issuer *x509.Certificate) ([]byte, error)
+
+// HTTPOCSPFetcher returns an OCSPFetcher that queries the OCSP responder
+// named in the certificate (via HTTP POST), using `client` (or
+// http.DefaultClient if it is nil).
+func HTTPOCSPFetcher(client *http.Client) OCSPFetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context, leaf, issuer *x509.Certificate) ([]byte, error) {
+		if len(leaf.OCSPServer) == 0 {
+			return nil, errors.New("certificate does not specify an OCSP responder")
+		}
+		request, err := ocsp.CreateRequest(leaf, issuer, nil)
+		if err != nil {
+			return nil, err
+		}
+		httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, leaf.OCSPServer[0], bytes.NewReader(request))
+		if err != nil {
+			return nil, err
+		}
+		httpRequest.Header.Set("Content-Type", "application/ocsp-request")
+		response, err := client.Do(httpRequest)
+		if err != nil {
+			return nil, err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("OCSP responder returned status %d", response.StatusCode)
+		}
+		return io.ReadAll(io.LimitReader(response.Body, maxOCSPResponseSize))
+	}
+}
+
+// staple attaches an OCSP staple to a newly loaded certificate, if one is
+// configured. Failure to obtain a staple does not fail the load: the
+// previous staple is retained if it is still valid for the certificate,
+// and otherwise the certificate is served without a staple.
+func (a *AutoreloadingCertStore) staple(cert *tls.Certificate) {
+	if a.OCSPStapleFile == "" && a.OCSPFetcher == nil {
+		return
+	}
+	refreshAt, err := a.loadStaple(cert)
+	if err != nil {
+		log.Printf("Failed to load OCSP staple: %v\n", err)
+		refreshAt = a.clock().Now().Add(ocspRetryInterval)
+		if previous := a.Get(); previous != nil && len(previous.Certificate) != 0 && len(cert.Certificate) != 0 &&
+			bytes.Equal(previous.Certificate[0], cert.Certificate[0]) && previous.OCSPStaple != nil {
+			if previousRefreshAt, err := a.validateStaple(cert, previous.OCSPStaple); err == nil {
+				cert.OCSPStaple = previous.OCSPStaple
+				if previousRefreshAt.Before(refreshAt) {
+					refreshAt = previousRefreshAt
+				}
+			}
+		}
+	}
+	a.scheduleStapleRefresh(refreshAt)
+}
+
+// loadStaple loads and validates a staple, returning the time at which it
+// should be refreshed.
+func (a *AutoreloadingCertStore) loadStaple(cert *tls.Certificate) (refreshAt time.Time, err error) {
+	var response []byte
+	if a.OCSPStapleFile != "" {
+		response, err = a.fs().ReadFile(a.OCSPStapleFile)
+	} else {
+		var leaf, issuer *x509.Certificate
+		if leaf, issuer, err = certificateIssuer(cert); err != nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(a.context(), a.ocspTimeout())
+		defer cancel()
+		response, err = a.OCSPFetcher(ctx, leaf, issuer)
+	}
+	if err != nil {
+		return
+	}
+	if refreshAt, err = a.validateStaple(cert, response); err == nil {
+		cert.OCSPStaple = response
+	}
+	return
+}
+
+// validateStaple checks that a DER-encoded OCSP response is a currently valid
+// "good" response for the certificate, signed by its issuer (or a responder
+// delegated by the issuer). It returns the time at which the staple should be
+// refreshed: halfway through its validity period.
+func (a *AutoreloadingCertStore) validateStaple(cert *tls.Certificate, response []byte) (refreshAt time.Time, err error) {
+	leaf, issuer, err := certificateIssuer(cert)
+	if err != nil {
+		return
+	}
+	parsed, err := ocsp.ParseResponseForCert(response, leaf, issuer)
+	if err != nil {
+		return
+	}
+	switch parsed.Status {
+	case ocsp.Good:
+	case ocsp.Revoked:
+		return refreshAt, fmt.Errorf("%w: %s (according to OCSP)", ErrCertificateRevoked, leaf.Subject)
+	default:
+		return refreshAt, fmt.Errorf("OCSP status of %s is unknown", leaf.Subject)
+	}
+	now := a.clock().Now()
+	if now.Before(parsed.ThisUpdate) {
+		return refreshAt, errors.New("OCSP response is not yet valid")
+	}
+	if parsed.NextUpdate.IsZero() {
+		// the responder didn't specify; use the retry interval as the lifetime
+		return now.Add(ocspRetryInterval), nil
+	}
+	if !now.Before(parsed.NextUpdate) {
+		return refreshAt, errors.New("OCSP response has expired")
+	}
+	return parsed.ThisUpdate.Add(parsed.NextUpdate.Sub(parsed.ThisUpdate) / 2), nil
+}
+
+// certificateIssuer returns the leaf certificate of a chain, and its issuer
+// (which must be the next certificate in the chain).
+func certificateIssuer(cert *tls.Certificate) (leaf, issuer *x509.Certificate, err error) {
+	if leaf, err = certificateLeaf(cert); err != nil {
+		return
+	}
+	if len(cert.Certificate) < 2 {
+		return nil, nil, errors.New("certificate chain does not include the issuer")
+	}
+	issuer, err = x509.ParseCertificate(cert.Certificate[1])
+	return
+}
+
+func (a *AutoreloadingCertStore) scheduleStapleRefresh(refreshAt time.Time) {
+	delay := refreshAt.Sub(a.clock().Now())
+	if delay < ocspRetryInterval {
+		delay = ocspRetryInterval
+	}
+	a.ocspMutex.Lock()
+	defer a.ocspMutex.Unlock()
+	if a.ocspStopped {
+		return
+	}
+	if a.ocspTimer != nil {
+		a.ocspTimer.Stop()
+	}
+	a.ocspTimer = a.clock().AfterFunc(delay, a.refreshStaple)
+}
+
+// refreshStaple attaches a new staple to a copy of the current certificate,
+// and swaps it in. This doesn't reload the certificate, since a reload would
+// record a new version (evicting real rollback targets from the history),
+// run HealthCheck, and notify subscribers, although nothing has changed.
+func (a *AutoreloadingCertStore) refreshStaple() {
+	current := a.current.Load()
+	if current == nil || current.Value == nil || len(current.Value.Certificate) == 0 {
+		return
+	}
+	cert := *current.Value
+	// staple() retains the current staple if it is still valid, and
+	// reschedules the refresh regardless of whether it succeeded:
+	cert.OCSPStaple = nil
+	a.staple(&cert)
+
+	a.reloadMutex.Lock()
+	defer a.reloadMutex.Unlock()
+	if a.current.Load() != current {
+		// a reload swapped in a new certificate (with its own staple) meanwhile
+		return
+	}
+	updated := *current
+	updated.Value = &cert
+	a.current.Store(&updated)
+}
+
+func (a *AutoreloadingCertStore) stopStapleRefresh() {
+	a.ocspMutex.Lock()
+	defer a.ocspMutex.Unlock()
+	a.ocspStopped = true
+	if a.ocspTimer != nil {
+		a.ocspTimer.Stop()
+		a.ocspTimer = nil
+	}
+}
+
+func (a *AutoreloadingCertStore) ocspTimeout() time.Duration {
+	if a.LoadTimeout != 0 {
+		return a.LoadTimeout
+	}
+	return 10 * time.Second
+}
@@ -0,0 +1,293 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrNoCertificate = errors.New("no certificate available")
+
+// CertKeyPair identifies a certificate file and its corresponding key file.
+type CertKeyPair struct {
+	CertFile string
+	KeyFile  string
+}
+
+/*
+AutoreloadingSNICertStore serves multiple auto-reloading TLS certificates,
+selecting among them according to the SNI (server name indication) sent
+by the client. Each certificate is an independent AutoreloadingCertStore.
+Names are matched exactly, then against wildcard certificates (*.example.com
+matches www.example.com, but neither example.com nor a.www.example.com);
+if there is no match (or the client didn't send SNI), the default
+certificate is used, which is the first one in Pairs (or in Dir, if
+Pairs is empty).
+
+Example usage:
+
+	certStore := AutoreloadingSNICertStore{
+		Dir:           "/etc/ssl/sites",
+		CheckInterval: time.Hour,
+	}
+	if err := certStore.Initialize(); err != nil {
+		log.Fatal(err)
+	}
+	listener, err := tls.Listen("tcp", ":443", certStore.TLSConfig())
+*/
+type AutoreloadingSNICertStore struct {
+	// Pairs is a list of certificate and key files.
+	Pairs []CertKeyPair
+	// Dir, if set, is a directory containing pairs of files named
+	// <name>.crt and <name>.key. It is rescanned every CheckInterval,
+	// so pairs can be added and removed at runtime.
+	Dir string
+	// CheckInterval is the interval on which each certificate is checked
+	// for updates (and Dir is rescanned).
+	CheckInterval time.Duration
+
+	mutex     sync.Mutex
+	stores    map[CertKeyPair]*AutoreloadingCertStore
+	scanTimer *time.Timer
+	stopped   bool
+
+	// indexMutex is separate from mutex, since the index is rebuilt by
+	// subscriptions that may fire while mutex is held:
+	indexMutex sync.Mutex
+	ordered    []*AutoreloadingCertStore
+	index      atomic.Pointer[sniIndex]
+}
+
+type sniIndex struct {
+	names       map[string]*tls.Certificate
+	defaultCert *tls.Certificate
+}
+
+// Initialize loads all the certificates and starts autoreloading them.
+// It returns an error if any of them failed to load; the remaining
+// certificates are served regardless, and the failed ones will be
+// retried on the next check (if CheckInterval is set), or by Refresh.
+func (s *AutoreloadingSNICertStore) Initialize() (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stores = make(map[CertKeyPair]*AutoreloadingCertStore)
+	err = s.scan()
+	if s.Dir != "" && s.CheckInterval != 0 {
+		s.scanTimer = time.AfterFunc(s.CheckInterval, s.rescan)
+	}
+	return
+}
+
+// scan brings the set of certificate stores up to date with Pairs and Dir;
+// the caller must hold the mutex.
+func (s *AutoreloadingSNICertStore) scan() (err error) {
+	pairs := append([]CertKeyPair(nil), s.Pairs...)
+	if s.Dir != "" {
+		dirPairs, dirErr := scanCertDir(s.Dir)
+		if dirErr != nil {
+			err = dirErr
+		}
+		pairs = append(pairs, dirPairs...)
+	}
+
+	current := make(map[CertKeyPair]bool, len(pairs))
+	ordered := make([]*AutoreloadingCertStore, 0, len(pairs))
+	var failed []string
+	for _, pair := range pairs {
+		current[pair] = true
+		if store, ok := s.stores[pair]; ok {
+			ordered = append(ordered, store)
+			continue
+		}
+		store := new(AutoreloadingCertStore)
+		store.Subscribe(func(ConfigChange[tls.Certificate]) {
+			s.rebuildIndex()
+		})
+		if loadErr := store.Initialize(pair.CertFile, pair.KeyFile, s.CheckInterval); loadErr != nil {
+			failed = append(failed, pair.CertFile)
+		}
+		s.stores[pair] = store
+		ordered = append(ordered, store)
+	}
+
+	s.indexMutex.Lock()
+	s.ordered = ordered
+	s.indexMutex.Unlock()
+	s.rebuildIndex()
+
+	for pair, store := range s.stores {
+		if !current[pair] {
+			store.Stop()
+			delete(s.stores, pair)
+		}
+	}
+
+	if err == nil && len(failed) != 0 {
+		err = fmt.Errorf("failed to load certificates: %s", strings.Join(failed, ", "))
+	}
+	return
+}
+
+func (s *AutoreloadingSNICertStore) rescan() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stopped {
+		return
+	}
+	s.scan()
+	s.scanTimer.Reset(s.CheckInterval)
+}
+
+// Refresh rescans Dir (if set) and reloads all the certificates, returning
+// an error if any of them failed to load. It implements Reloadable, so the
+// store can be used with SignalReloader.
+func (s *AutoreloadingSNICertStore) Refresh() (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	err = s.scan()
+
+	s.indexMutex.Lock()
+	ordered := s.ordered
+	s.indexMutex.Unlock()
+	var failed []string
+	for _, store := range ordered {
+		if _, loadErr := store.Reload(); loadErr != nil {
+			failed = append(failed, store.Path)
+		}
+	}
+	if len(failed) != 0 {
+		err = fmt.Errorf("failed to reload certificates: %s", strings.Join(failed, ", "))
+	}
+	return
+}
+
+// scanCertDir finds pairs of files named <name>.crt and <name>.key
+func scanCertDir(dir string) (pairs []CertKeyPair, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	// ReadDir returns the entries sorted by name
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".crt") || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".crt")
+		keyFile := filepath.Join(dir, name+".key")
+		if isRegularFile(keyFile) {
+			pairs = append(pairs, CertKeyPair{CertFile: filepath.Join(dir, entry.Name()), KeyFile: keyFile})
+		}
+	}
+	return
+}
+
+// rebuildIndex recomputes the mapping from names to certificates.
+func (s *AutoreloadingSNICertStore) rebuildIndex() {
+	s.indexMutex.Lock()
+	defer s.indexMutex.Unlock()
+
+	index := &sniIndex{names: make(map[string]*tls.Certificate)}
+	for _, store := range s.ordered {
+		cert := store.Get()
+		if cert == nil || len(cert.Certificate) == 0 {
+			continue
+		}
+		leaf, err := certificateLeaf(cert)
+		if err != nil {
+			continue
+		}
+		if index.defaultCert == nil {
+			index.defaultCert = cert
+		}
+		for _, name := range certificateNames(leaf) {
+			// earlier pairs take precedence:
+			name = normalizeServerName(name)
+			if _, exists := index.names[name]; !exists {
+				index.names[name] = cert
+			}
+		}
+	}
+	s.index.Store(index)
+}
+
+// GetCertificate is a callback suitable for use as (*tls.Config).GetCertificate:
+// it selects a certificate according to the SNI in the ClientHello.
+func (s *AutoreloadingSNICertStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	index := s.index.Load()
+	if index == nil {
+		return nil, ErrNoCertificate
+	}
+	if hello != nil && hello.ServerName != "" {
+		name := normalizeServerName(hello.ServerName)
+		if cert, ok := index.names[name]; ok {
+			return cert, nil
+		}
+		if _, parent, found := strings.Cut(name, "."); found {
+			if cert, ok := index.names["*."+parent]; ok {
+				return cert, nil
+			}
+		}
+	}
+	if index.defaultCert == nil {
+		return nil, ErrNoCertificate
+	}
+	return index.defaultCert, nil
+}
+
+// TLSConfig returns a *tls.Config with a GetCertificate member that uses the store.
+// Callers may wish to populate other fields.
+func (s *AutoreloadingSNICertStore) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: s.GetCertificate,
+	}
+}
+
+// Stop stops autoreloading all the certificates.
+func (s *AutoreloadingSNICertStore) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stopped = true
+	if s.scanTimer != nil {
+		s.scanTimer.Stop()
+	}
+	for _, store := range s.stores {
+		store.Stop()
+	}
+}
+
+// certificateLeaf returns the parsed leaf certificate, parsing it if necessary.
+func certificateLeaf(cert *tls.Certificate) (*x509.Certificate, error) {
+	if cert.Leaf != nil {
+		return cert.Leaf, nil
+	}
+	if len(cert.Certificate) == 0 {
+		return nil, ErrNoCertificate
+	}
+	return x509.ParseCertificate(cert.Certificate[0])
+}
+
+// certificateNames returns the DNS names a certificate is valid for
+// (falling back to the Common Name for legacy certificates with no SANs).
+func certificateNames(leaf *x509.Certificate) []string {
+	if len(leaf.DNSNames) != 0 {
+		return leaf.DNSNames
+	}
+	if leaf.Subject.CommonName != "" && len(leaf.IPAddresses) == 0 {
+		return []string{leaf.Subject.CommonName}
+	}
+	return nil
+}
+
+func normalizeServerName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const sessionTicketKeySize = 32
+
+var ErrNoSessionTicketKeys = errors.New("no session ticket keys found")
+
+// SessionTicketKeys is a loaded set of TLS session ticket keys. The first
+// key is used to encrypt new tickets; all of them are used for decryption.
+type SessionTicketKeys struct {
+	Keys [][sessionTicketKeySize]byte
+}
+
+/*
+AutoreloadingSessionTicketKeyStore is a store for TLS session ticket keys,
+shared by several servers (for example, the instances behind a load balancer)
+via a file, so that a session resumed on any instance can be decrypted.
+When the file is updated, the new keys are applied to the registered
+*tls.Config instances; keys from the previous load are retained for
+decryption, so that tickets issued before the rotation remain valid.
+
+Example usage:
+
+	var tickets godgets.AutoreloadingSessionTicketKeyStore
+	if err := tickets.Initialize("/run/secrets/ticket-keys", time.Minute); err != nil {
+		log.Fatal(err)
+	}
+	config := certStore.TLSConfig()
+	tickets.Apply(config)
+	listener, err := tls.Listen("tcp", ":443", config)
+*/
+type AutoreloadingSessionTicketKeyStore struct {
+	AutoreloadingConfigStore[SessionTicketKeys]
+	// PreviousKeys is the number of keys from earlier loads (that are no
+	// longer in the file) to retain for decryption; a zero value means
+	// a default of 1, and a negative value means none.
+	PreviousKeys int
+}
+
+// Initialize loads the keys from `keyfile`, which contains either the raw
+// keys (32 bytes each), or one base64-encoded key per line, with the current
+// key first.
+func (a *AutoreloadingSessionTicketKeyStore) Initialize(keyfile string, checkInterval time.Duration) error {
+	a.Path = keyfile
+	a.CheckInterval = checkInterval
+	a.LoadCallback = func(path string) (*SessionTicketKeys, error) {
+		keys, err := a.loadKeys(path)
+		if err != nil {
+			log.Printf("Failed to reload session ticket keys: %v\n", err)
+		}
+		return keys, err
+	}
+	_, err := a.AutoreloadingConfigStore.Initialize()
+	return err
+}
+
+func (a *AutoreloadingSessionTicketKeyStore) loadKeys(path string) (*SessionTicketKeys, error) {
+	data, err := a.fs().ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseSessionTicketKeys(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// retain keys from the previous load that are no longer in the file:
+	if previous := a.Get(); previous != nil {
+		retained := 0
+		for _, key := range previous.Keys {
+			if retained >= a.previousKeys() {
+				break
+			}
+			if !containsTicketKey(keys, key) {
+				keys = append(keys, key)
+				retained++
+			}
+		}
+	}
+	return &SessionTicketKeys{Keys: keys}, nil
+}
+
+func (a *AutoreloadingSessionTicketKeyStore) previousKeys() int {
+	if a.PreviousKeys < 0 {
+		return 0
+	} else if a.PreviousKeys == 0 {
+		return 1
+	}
+	return a.PreviousKeys
+}
+
+func parseSessionTicketKeys(data []byte) (keys [][sessionTicketKeySize]byte, err error) {
+	if len(data) != 0 && len(data)%sessionTicketKeySize == 0 && !isBase64Lines(data) {
+		for i := 0; i < len(data); i += sessionTicketKeySize {
+			var key [sessionTicketKeySize]byte
+			copy(key[:], data[i:])
+			keys = append(keys, key)
+		}
+		return
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(string(line))
+		if err != nil {
+			return nil, err
+		}
+		if len(decoded) != sessionTicketKeySize {
+			return nil, fmt.Errorf("session ticket key has length %d, expected %d", len(decoded), sessionTicketKeySize)
+		}
+		var key [sessionTicketKeySize]byte
+		copy(key[:], decoded)
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoSessionTicketKeys
+	}
+	return
+}
+
+// isBase64Lines determines whether data consists entirely of base64 text,
+// to distinguish it from raw keys.
+func isBase64Lines(data []byte) bool {
+	for _, b := range data {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9':
+		case b == '+', b == '/', b == '=', b == '\n', b == '\r', b == ' ', b == '#':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func containsTicketKey(keys [][sessionTicketKeySize]byte, key [sessionTicketKeySize]byte) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply sets the session ticket keys of `config` to the current keys, and
+// updates them whenever the keys are reloaded, until `remove` is called.
+// Note that configs returned by GetConfigForClient are not updated, unless
+// they are also passed to Apply.
+func (a *AutoreloadingSessionTicketKeyStore) Apply(config *tls.Config) (remove func()) {
+	// prevent loads between applying the current keys and subscribing:
+	a.reloadMutex.Lock()
+	defer a.reloadMutex.Unlock()
+	if keys := a.Get(); keys != nil {
+		config.SetSessionTicketKeys(keys.Keys)
+	}
+	return a.Subscribe(func(change ConfigChange[SessionTicketKeys]) {
+		// this includes rollbacks, which are delivered with an error:
+		if change.New != nil && change.New != change.Old {
+			config.SetSessionTicketKeys(change.New.Keys)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrCertKeyMismatch = errors.New("certificate and private key do not match")
+
+// verifyCertificate checks the chain and hostnames of a newly loaded
+// certificate, according to RootCAs, KeyUsages, and ExpectedHostnames.
+func (a *AutoreloadingCertStore) verifyCertificate(cert *tls.Certificate) error {
+	leaf, err := certificateLeaf(cert)
+	if err != nil {
+		return err
+	}
+	if a.RootCAs != nil {
+		intermediates := x509.NewCertPool()
+		for _, der := range cert.Certificate[1:] {
+			intermediate, err := x509.ParseCertificate(der)
+			if err != nil {
+				return err
+			}
+			intermediates.AddCert(intermediate)
+		}
+		_, err := leaf.Verify(x509.VerifyOptions{
+			Roots:         a.RootCAs,
+			Intermediates: intermediates,
+			CurrentTime:   a.clock().Now(),
+			KeyUsages:     a.keyUsages(),
+		})
+		if err != nil {
+			return fmt.Errorf("certificate chain verification failed: %w", err)
+		}
+	}
+	for _, hostname := range a.ExpectedHostnames {
+		if err := leaf.VerifyHostname(hostname); err != nil {
+			return fmt.Errorf("certificate is not valid for expected hostname: %w", err)
+		}
+	}
+	return nil
+}
+
+func (a *AutoreloadingCertStore) keyUsages() []x509.ExtKeyUsage {
+	if a.KeyUsages != nil {
+		return a.KeyUsages
+	}
+	return []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+}
+
+func (a *AutoreloadingCertStore) mismatchRetryInterval() time.Duration {
+	if a.MismatchRetryInterval != 0 {
+		return a.MismatchRetryInterval
+	}
+	return time.Second
+}
+
+// keyPairMismatch determines whether a certificate and a key are each
+// valid, but don't correspond to each other.
+func keyPairMismatch(certPEMBlock, keyPEMBlock []byte) bool {
+	var leaf *x509.Certificate
+	var key crypto.Signer
+	for {
+		var block *pem.Block
+		block, certPEMBlock = pem.Decode(certPEMBlock)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			leaf, _ = x509.ParseCertificate(block.Bytes)
+			break
+		}
+	}
+	for {
+		var block *pem.Block
+		block, keyPEMBlock = pem.Decode(keyPEMBlock)
+		if block == nil {
+			break
+		}
+		if block.Type == "PRIVATE KEY" || strings.HasSuffix(block.Type, " PRIVATE KEY") {
+			key, _ = parsePrivateKey(block.Bytes)
+			break
+		}
+	}
+	if leaf == nil || key == nil {
+		return false
+	}
+	return !publicKeysEqual(leaf.PublicKey, key.Public())
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	publicKey, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && publicKey.Equal(b)
+}
+
+// parsePrivateKey parses a DER-encoded private key in any of the formats
+// accepted by crypto/tls (PKCS #1, PKCS #8, or SEC 1).
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		switch key := key.(type) {
+		case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+			return key.(crypto.Signer), nil
+		default:
+			return nil, errors.New("unsupported private key type")
+		}
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("failed to parse private key")
+}
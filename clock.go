@@ -0,0 +1,134 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock abstracts over the system clock, so that code depending on it can be
+// tested deterministically (see FakeClock).
+type Clock interface {
+	Now() time.Time
+	// AfterFunc has the semantics of time.AfterFunc.
+	AfterFunc(d time.Duration, f func()) ClockTimer
+}
+
+// ClockTimer is the subset of the *time.Timer API returned by (Clock).AfterFunc.
+type ClockTimer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	return time.AfterFunc(d, f)
+}
+
+// FakeClock is a Clock whose time only moves when Advance() is called;
+// timers fire synchronously, in the goroutine calling Advance().
+type FakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a FakeClock whose current time is `now`.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	timer := &fakeTimer{clock: c, f: f}
+	c.timers = append(c.timers, timer)
+	timer.schedule(d)
+	return timer
+}
+
+// Advance moves the clock forward by `d`, firing any timers that come due,
+// in order of their deadlines (and with the clock set to each deadline in
+// turn). Timers that are scheduled or reset by the callbacks also fire if
+// they come due before the new time.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	target := c.now.Add(d)
+	c.mutex.Unlock()
+
+	for {
+		c.mutex.Lock()
+		var next *fakeTimer
+		for _, timer := range c.timers {
+			if timer.active && !timer.when.After(target) && (next == nil || timer.when.Before(next.when)) {
+				next = timer
+			}
+		}
+		if next == nil {
+			c.now = target
+			c.mutex.Unlock()
+			return
+		}
+		c.now = next.when
+		next.active = false
+		c.mutex.Unlock()
+		// run the callback without holding the mutex, since it may
+		// interact with the clock:
+		next.f()
+	}
+}
+
+// PendingTimers returns the number of timers that have not yet fired
+// (or been stopped).
+func (c *FakeClock) PendingTimers() (count int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, timer := range c.timers {
+		if timer.active {
+			count++
+		}
+	}
+	return
+}
+
+type fakeTimer struct {
+	clock  *FakeClock
+	f      func()
+	when   time.Time
+	active bool
+}
+
+// schedule arms the timer; the caller must hold the clock's mutex
+func (t *fakeTimer) schedule(d time.Duration) {
+	t.when = t.clock.now.Add(d)
+	t.active = true
+}
+
+func (t *fakeTimer) Stop() (wasActive bool) {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	wasActive = t.active
+	t.active = false
+	return
+}
+
+func (t *fakeTimer) Reset(d time.Duration) (wasActive bool) {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	wasActive = t.active
+	t.schedule(d)
+	return
+}
@@ -4,7 +4,14 @@
 package godgets
 
 import (
-	"os"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,8 +21,10 @@ import (
    AutoreloadingConfigStore is a configuration file store with the following properties:
    1. If Initialize() succeeds, subsequent calls to Get() return a valid (possibly stale) value
    2. Get() is a wait-free atomic pointer load
-   3. The configuration is atomically reloaded in the background if stat(2) shows an updated mtime
-   4. ReloadIfChanged() synchronously checks the mtime and returns an up-to-date value, reloading if necessary
+   3. The configuration is atomically reloaded in the background if the file has changed
+      (by default, if stat(2) shows an updated mtime; see ChangeDetector), either on a timer
+      or in response to inotify(7) events if Watch is set
+   4. ReloadIfChanged() synchronously checks for changes and returns an up-to-date value, reloading if necessary
 
 Example usage:
 
@@ -44,91 +53,360 @@ type AutoreloadingConfigStore[T any] struct {
 	// in this case, the stored value will not be updated (except during
 	// Initialize(), when there is no existing stored value to prefer).
 	LoadCallback func(string) (*T, error)
+	// LoadContextCallback, if set, is used instead of LoadCallback; it receives
+	// a context that is cancelled if the load times out (see LoadTimeout) or
+	// the store's context (see InitializeContext) is cancelled.
+	LoadContextCallback func(ctx context.Context, path string) (*T, error)
+	// LoadTimeout, if nonzero, bounds the duration of each load. If a load
+	// times out, it fails with an error wrapping context.DeadlineExceeded;
+	// a LoadCallback that doesn't respect the context (for example, a read
+	// that is hung on NFS) is abandoned to complete in the background,
+	// and its result is discarded.
+	LoadTimeout time.Duration
+	// Source, if set, supersedes Path and LoadCallback: the config data
+	// is fetched from Source and decoded with DecodeCallback. Changes are
+	// detected using the source's version tokens, so ChangeDetector and
+	// Watch do not apply.
+	Source ConfigSource
+	// DecodeCallback decodes data fetched from Source, performing any
+	// necessary postprocessing and validation (like LoadCallback). It can
+	// also be used instead of LoadCallback with a Path, in which case the
+	// file is read via FS.
+	DecodeCallback func(data []byte) (*T, error)
+	// Clock, if set, replaces the system clock for scheduling checks and
+	// timestamping loads (see FakeClock). It does not apply to LoadTimeout
+	// and HealthCheckWindow, which use real time.
+	Clock Clock
+	// FS, if set, replaces the real filesystem for checking Path for changes
+	// (and reading it, if DecodeCallback is used); see MemFS.
+	FS ConfigFS
 	// CheckInterval is the interval on which we check for updates to the file.
 	// A zero value means automatic scheduled checks are disabled.
 	CheckInterval time.Duration
+	// CheckJitter randomizes each scheduled check by up to this fraction of
+	// the interval in either direction (e.g., 0.1 for +/- 10%), so that a fleet
+	// of instances started together doesn't check in lockstep.
+	CheckJitter float64
+	// MaxBackoff, if nonzero, enables exponential backoff after failed loads:
+	// after n consecutive failures (see ConfigStatus.ConsecutiveFailures),
+	// the next scheduled check is delayed by CheckInterval * 2^n, up to
+	// a maximum of MaxBackoff.
+	MaxBackoff time.Duration
+	// Watch enables change detection via inotify(7) (on Linux only), instead of
+	// polling stat(2) every CheckInterval. Both the file and its parent directory
+	// are watched, so replacing the file with rename(2) (or swapping a symlink,
+	// as with a Kubernetes ConfigMap) is detected. If watching is unavailable,
+	// we fall back to polling on CheckInterval.
+	Watch bool
+	// WatchDebounce is the quiet period to wait after a filesystem event before
+	// checking the file, so that a burst of writes produces a single reload.
+	// A zero value means a default of 100 milliseconds.
+	WatchDebounce time.Duration
+	// ChangeDetector determines how we decide whether the file has changed;
+	// the zero value is DetectMtime.
+	ChangeDetector ChangeDetector
+	// ValidateCallback, if set, is called with the currently stored value and
+	// a newly loaded one, before the new value is stored. It can return a
+	// non-nil error to veto the swap (for example, if the change cannot take
+	// effect without a restart); this is treated like a load error. During
+	// Initialize(), `old` is nil.
+	ValidateCallback func(old, new *T) error
+	// HealthCheck, if set, is called after a reloaded value has been stored
+	// and subscribers have been notified, so it can verify that the
+	// application is healthy with the new value. If it returns a non-nil
+	// error, the store rolls back to the previous value (subscribers are
+	// notified of this as well), and the rejected file will not be reloaded
+	// again until it changes. HealthCheck is not called during Initialize(),
+	// since there is nothing to roll back to.
+	HealthCheck func(ctx context.Context, value *T) error
+	// HealthCheckWindow bounds how long the health check may take; it is
+	// applied as the deadline of the context passed to HealthCheck, and
+	// a zero value means no deadline.
+	HealthCheckWindow time.Duration
+	// HistorySize is the number of versions (including the current one) to
+	// retain for History() and Rollback(); a zero value means a default of 8.
+	HistorySize int
+	// Metrics, if set, is notified of the outcome and latency of every load
+	// (see ReloadMetrics).
+	Metrics ReloadObserver
 
+	// reloadMutex serializes loads, so that subscribers see changes in order:
+	reloadMutex sync.Mutex
 	stateMutex  sync.Mutex
-	value       atomic.Pointer[T]
-	mtime       time.Time
-	reloadTimer *time.Timer
+	current     atomic.Pointer[ConfigVersion[T]]
+	reloadTimer ClockTimer
+	watcher     io.Closer
 	stopped     bool
+	subscribers []configSubscriber[T]
+	nextSubID   uint64
+	history     []*ConfigVersion[T]
+	generation  uint64
+	status      ConfigStatus
+	derivations []func(*T) (any, error)
+	// ctx is the context passed to InitializeContext:
+	ctx       context.Context
+	stopEvent Event
+	// backend, if set, supersedes Path and LoadCallback:
+	backend configBackend[T]
 }
 
+// configBackend abstracts over the source of a config, allowing variants of
+// the store to reuse its loading and reloading machinery.
+type configBackend[T any] interface {
+	// fingerprint identifies the current state of the source; it should be
+	// the empty string if the source is inaccessible.
+	fingerprint(detector ChangeDetector) string
+	load(ctx context.Context) (*T, error)
+	// watchedPaths returns the paths to watch with inotify(7).
+	watchedPaths() []string
+	modTime() time.Time
+}
+
+// ErrConfigRolledBack is returned (wrapped) by Reload() when a new value
+// failed its HealthCheck and the previous value was restored.
+var ErrConfigRolledBack = errors.New("config rolled back after failed health check")
+
+// RetryAfter wraps an error returned by a LoadCallback, requesting that the
+// next scheduled check happen after `delay` (instead of CheckInterval, with
+// any backoff). This is appropriate for errors that are likely to be transient.
+func RetryAfter(err error, delay time.Duration) error {
+	return &retryAfterError{err: err, delay: delay}
+}
+
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (r *retryAfterError) Error() string {
+	return r.err.Error()
+}
+
+func (r *retryAfterError) Unwrap() error {
+	return r.err
+}
+
+func (r *retryAfterError) RetryAfter() time.Duration {
+	return r.delay
+}
+
+// ConfigVersion is a loaded value of the config, together with the fingerprint
+// of the file it was loaded from.
+type ConfigVersion[T any] struct {
+	Value *T
+	// Generation is incremented every time a value is stored.
+	Generation uint64
+	// LoadedAt is the time at which the value was stored.
+	LoadedAt time.Time
+	// ModTime is the mtime of the file the value was loaded from, if known
+	// (for a multi-file store, it is the latest mtime of any member).
+	ModTime time.Time
+	// Fingerprint identifies the contents of the file, as determined by the
+	// store's ChangeDetector (or, for a Source, it is the version token);
+	// it is empty if the file could not be accessed.
+	// After a rollback due to a failed HealthCheck, it identifies the rejected
+	// file, so that it isn't reloaded again until it changes.
+	Fingerprint string
+
+	// values computed by Derive(), indexed like the store's derivations:
+	derived []any
+}
+
+// ChangeDetector is a strategy for detecting changes to a file.
+type ChangeDetector uint
+
+const (
+	// DetectMtime reloads when the mtime changes; this is the cheapest check,
+	// but it will miss changes that preserve the mtime (e.g. `rsync -t`).
+	DetectMtime ChangeDetector = iota
+	// DetectStat reloads when the size, mtime, or inode number changes.
+	DetectStat
+	// DetectContentHash reloads when the SHA-256 of the contents changes.
+	// This reads the whole file on every check, but reloads exactly when
+	// the bytes change (for example, not on touch(1)).
+	DetectContentHash
+)
+
 // Initialize initializes the store, performing an initial load and returning
 // the value, with the load error if applicable. If autoreloading is enabled,
 // attempts to autoreload are scheduled even if the initial load returned
 // an error.
 func (a *AutoreloadingConfigStore[T]) Initialize() (value *T, err error) {
-	mtime := getMtime(a.Path)
-	value, err = a.LoadCallback(a.Path)
+	return a.InitializeContext(context.Background())
+}
+
+// InitializeContext is like Initialize, but ties the lifetime of the store to
+// `ctx`: loads receive a context derived from it, and when it is cancelled,
+// the store is stopped as if by Stop().
+func (a *AutoreloadingConfigStore[T]) InitializeContext(ctx context.Context) (value *T, err error) {
+	a.reloadMutex.Lock()
+	defer a.reloadMutex.Unlock()
+
+	a.stateMutex.Lock()
+	a.ctx = ctx
+	a.stopEvent = NewEvent()
+	a.stateMutex.Unlock()
+	if ctx.Done() != nil {
+		go func(stopEvent Event) {
+			select {
+			case <-ctx.Done():
+				a.Stop()
+			case <-stopEvent:
+			}
+		}(a.stopEvent)
+	}
+
+	start := a.clock().Now()
+	value, fingerprint, _, err := a.loadIfChanged("", true)
+	latency := a.clock().Now().Sub(start)
+	if err == nil && a.ValidateCallback != nil {
+		err = a.ValidateCallback(nil, value)
+	}
+	var derived []any
+	if err == nil {
+		derived, err = a.derive(value)
+	}
+	if err != nil {
+		// don't record the fingerprint of a failed load, so that the next
+		// check retries it even if the file is unchanged (e.g., if another
+		// file that the load depends on was fixed):
+		fingerprint = ""
+	}
+	a.store(&ConfigVersion[T]{Value: value, Fingerprint: fingerprint, ModTime: a.modTime(), derived: derived}, err == nil)
+	a.recordResult(err, latency)
+	a.notify(ConfigChange[T]{New: value, Err: err})
 
 	a.stateMutex.Lock()
 	defer a.stateMutex.Unlock()
-	a.value.Store(value)
-	a.mtime = mtime
-	if a.CheckInterval != 0 {
-		a.reloadTimer = time.AfterFunc(a.CheckInterval, a.autoreload)
+	if a.Watch && a.Source == nil {
+		a.watcher, _ = watchPaths(a.watchedPaths, a.watchDebounce(), a.autoreloadFromWatch)
+	}
+	if a.CheckInterval != 0 && a.watcher == nil {
+		a.reloadTimer = a.clock().AfterFunc(a.nextCheckInterval(), a.autoreload)
 	}
 	return
 }
 
 // Get returns the most recent valid value of the config. It is wait-free.
 func (a *AutoreloadingConfigStore[T]) Get() *T {
-	return a.value.Load()
+	if current := a.current.Load(); current != nil {
+		return current.Value
+	}
+	return nil
 }
 
-// ReloadIfChanged synchronously checks if the config has been updated on
-// disk. If it has not been updated, it returns the existing stored value.
-// If it has been updated, it reloads the config. If the config loads without
-// an error, it updates the stored value and returns it. If it loads with
-// an error, it returns the previously stored value, but with the error
-// value from loading the new config.
-func (a *AutoreloadingConfigStore[T]) ReloadIfChanged() (*T, error) {
-	a.stateMutex.Lock()
-	mtime := a.mtime
-	value := a.value.Load()
-	a.stateMutex.Unlock()
-
-	if curMtime := getMtime(a.Path); curMtime.After(mtime) {
-		return a.Reload()
-	} else {
-		return value, nil
-	}
+// Current returns the most recent valid value of the config, together with
+// the fingerprint of the file it was loaded from. It is wait-free.
+func (a *AutoreloadingConfigStore[T]) Current() *ConfigVersion[T] {
+	return a.current.Load()
 }
 
-// Reload synchronously and unconditionally reloads the config. If the config
+// ReloadIfChanged synchronously checks if the config has been updated on
+// disk (or in its Source). If it has not been updated, it returns the existing
+// stored value. If it has been updated, it reloads the config. If the config
 // loads without an error, it updates the stored value and returns it. If it
 // loads with an error, it returns the previously stored value, but with the
 // error value from loading the new config.
+func (a *AutoreloadingConfigStore[T]) ReloadIfChanged() (*T, error) {
+	return a.reload(false)
+}
+
+// Reload synchronously and unconditionally reloads the config. If the config
+// loads without an error (and passes ValidateCallback and HealthCheck, if
+// applicable), it updates the stored value and returns it. Otherwise, it
+// returns the previously stored value, but with the error value from
+// loading the new config.
 func (a *AutoreloadingConfigStore[T]) Reload() (*T, error) {
-	mtime := getMtime(a.Path)
-	value, err := a.LoadCallback(a.Path)
+	return a.reload(true)
+}
+
+func (a *AutoreloadingConfigStore[T]) reload(force bool) (*T, error) {
+	a.reloadMutex.Lock()
+	defer a.reloadMutex.Unlock()
+
+	previous := a.current.Load()
+	if previous == nil {
+		// not initialized
+		previous = new(ConfigVersion[T])
+	}
+	old := previous.Value
+	start := a.clock().Now()
+	value, fingerprint, changed, err := a.loadIfChanged(previous.Fingerprint, force)
+	if !changed {
+		return old, nil
+	}
+	latency := a.clock().Now().Sub(start)
+	if err == nil && a.ValidateCallback != nil {
+		err = a.ValidateCallback(old, value)
+	}
+	var derived []any
+	if err == nil {
+		derived, err = a.derive(value)
+	}
 
 	if err != nil {
 		// return the stale value with the error
-		return a.Get(), err
+		a.recordResult(err, latency)
+		a.notify(ConfigChange[T]{Old: old, New: old, Err: err})
+		return old, err
 	}
 
-	a.stateMutex.Lock()
-	defer a.stateMutex.Unlock()
-	a.value.Store(value)
-	a.mtime = mtime
+	a.commit(&ConfigVersion[T]{Value: value, Fingerprint: fingerprint, ModTime: a.modTime(), derived: derived})
+	a.notify(ConfigChange[T]{Old: old, New: value})
+
+	if err := a.healthCheck(value); err != nil {
+		// roll back, but keep the new fingerprint so that we don't
+		// repeatedly reload the same rejected file:
+		rollback := *previous
+		rollback.Fingerprint = fingerprint
+		a.commit(&rollback)
+		a.recordResult(err, latency)
+		a.notify(ConfigChange[T]{Old: value, New: old, Err: err})
+		return old, err
+	}
+	a.recordResult(nil, latency)
 	return value, nil
 }
 
+func (a *AutoreloadingConfigStore[T]) healthCheck(value *T) (err error) {
+	if a.HealthCheck == nil {
+		return nil
+	}
+	ctx := a.context()
+	if a.HealthCheckWindow != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.HealthCheckWindow)
+		defer cancel()
+	}
+	if err = a.HealthCheck(ctx, value); err != nil {
+		err = fmt.Errorf("%w: %v", ErrConfigRolledBack, err)
+	}
+	return
+}
+
 // Stop prevents the config from autoreloading further (enabling the
-// AutoreloadingConfigStore to be garbage-collected).
+// AutoreloadingConfigStore to be garbage-collected). If a reload is in
+// progress, it waits for it to complete (or, if LoadTimeout is set, time out);
+// no subscriber will be notified after Stop returns.
 func (a *AutoreloadingConfigStore[T]) Stop() {
+	a.reloadMutex.Lock()
+	defer a.reloadMutex.Unlock()
 	a.stateMutex.Lock()
 	defer a.stateMutex.Unlock()
 	a.stopped = true
+	if a.stopEvent != nil && !a.stopEvent.IsDone() {
+		a.stopEvent.Done()
+	}
 	if a.reloadTimer != nil {
 		// the current timer might have already fired;
 		// in that case, the reschedule operation will see `stopped`
 		// and refuse to reschedule
 		a.reloadTimer.Stop()
 	}
+	if a.watcher != nil {
+		a.watcher.Close()
+		a.watcher = nil
+	}
 }
 
 func (a *AutoreloadingConfigStore[T]) autoreload() {
@@ -139,17 +417,210 @@ func (a *AutoreloadingConfigStore[T]) autoreload() {
 		// defensively check that the client didn't set CheckInterval to zero:
 		if !a.stopped && a.CheckInterval != 0 {
 			a.reloadTimer.Stop()
-			a.reloadTimer.Reset(a.CheckInterval)
+			a.reloadTimer.Reset(a.nextCheckInterval())
 		}
 	}()
 
 	a.ReloadIfChanged()
 }
 
-// return the mtime; if the file is inaccessible, return the zero value of time.Time
-func getMtime(path string) (mtime time.Time) {
-	if info, err := os.Stat(path); err == nil {
-		return info.ModTime()
+// nextCheckInterval computes the delay until the next scheduled check,
+// applying backoff and jitter; the caller must hold stateMutex.
+func (a *AutoreloadingConfigStore[T]) nextCheckInterval() time.Duration {
+	interval := a.CheckInterval
+	var retry interface{ RetryAfter() time.Duration }
+	if a.status.ConsecutiveFailures != 0 && errors.As(a.status.LastError, &retry) {
+		// the load callback requested a specific delay
+		interval = retry.RetryAfter()
+	} else if a.MaxBackoff != 0 && a.status.ConsecutiveFailures != 0 {
+		for i := 0; i < a.status.ConsecutiveFailures && interval < a.MaxBackoff; i++ {
+			interval *= 2
+		}
+		if interval > a.MaxBackoff {
+			interval = a.MaxBackoff
+		}
+	}
+	if a.CheckJitter != 0 {
+		interval += time.Duration(float64(interval) * a.CheckJitter * (2*rand.Float64() - 1))
+	}
+	if interval <= 0 {
+		interval = a.CheckInterval
+	}
+	return interval
+}
+
+func (a *AutoreloadingConfigStore[T]) autoreloadFromWatch() {
+	a.ReloadIfChanged()
+}
+
+func (a *AutoreloadingConfigStore[T]) fingerprint() string {
+	if a.backend != nil {
+		return a.backend.fingerprint(a.ChangeDetector)
+	}
+	return getFingerprint(a.fs(), a.Path, a.ChangeDetector)
+}
+
+// loadIfChanged checks whether the config has changed from the version
+// identified by `stored` (assuming it has if `force` is set); if so,
+// it loads the new version, returning its value and fingerprint.
+func (a *AutoreloadingConfigStore[T]) loadIfChanged(stored string, force bool) (value *T, fingerprint string, changed bool, err error) {
+	if a.Source != nil {
+		if force {
+			stored = ""
+		}
+		value, fingerprint, err = a.load(stored)
+		if errors.Is(err, ErrNotModified) {
+			return nil, stored, false, nil
+		}
+		return value, fingerprint, true, err
+	}
+
+	fingerprint = a.fingerprint()
+	// if the file is inaccessible, keep the existing value:
+	if !force && (fingerprint == "" || fingerprint == stored) {
+		return nil, fingerprint, false, nil
+	}
+	value, _, err = a.load(stored)
+	return value, fingerprint, true, err
+}
+
+// load performs a load, subject to the store's context and LoadTimeout.
+// For a Source, it also returns the new version token.
+func (a *AutoreloadingConfigStore[T]) load(stored string) (*T, string, error) {
+	ctx := a.context()
+	if a.LoadTimeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.LoadTimeout)
+		defer cancel()
+	}
+	if ctx.Done() == nil {
+		// cannot be cancelled
+		return a.loadContext(ctx, stored)
+	}
+
+	type loadResult struct {
+		value   *T
+		version string
+		err     error
+	}
+	// buffered, so that an abandoned load can complete without blocking:
+	results := make(chan loadResult, 1)
+	go func() {
+		value, version, err := a.loadContext(ctx, stored)
+		results <- loadResult{value, version, err}
+	}()
+	select {
+	case result := <-results:
+		return result.value, result.version, result.err
+	case <-ctx.Done():
+		return nil, "", fmt.Errorf("config load abandoned: %w", ctx.Err())
+	}
+}
+
+func (a *AutoreloadingConfigStore[T]) loadContext(ctx context.Context, stored string) (value *T, version string, err error) {
+	if a.Source != nil {
+		data, version, err := a.Source.Fetch(ctx, stored)
+		if err != nil {
+			return nil, "", err
+		}
+		value, err = a.DecodeCallback(data)
+		return value, version, err
+	} else if a.backend != nil {
+		value, err = a.backend.load(ctx)
+	} else if a.LoadContextCallback != nil {
+		value, err = a.LoadContextCallback(ctx, a.Path)
+	} else if a.LoadCallback != nil {
+		value, err = a.LoadCallback(a.Path)
+	} else {
+		var data []byte
+		if data, err = a.fs().ReadFile(a.Path); err == nil {
+			value, err = a.DecodeCallback(data)
+		}
+	}
+	return
+}
+
+func (a *AutoreloadingConfigStore[T]) clock() Clock {
+	if a.Clock != nil {
+		return a.Clock
+	}
+	return realClock{}
+}
+
+func (a *AutoreloadingConfigStore[T]) fs() ConfigFS {
+	if a.FS != nil {
+		return a.FS
+	}
+	return osFS{}
+}
+
+func (a *AutoreloadingConfigStore[T]) context() context.Context {
+	a.stateMutex.Lock()
+	defer a.stateMutex.Unlock()
+	if a.ctx != nil {
+		return a.ctx
+	}
+	return context.Background()
+}
+
+func (a *AutoreloadingConfigStore[T]) modTime() (mtime time.Time) {
+	if a.Source != nil {
+		return
+	} else if a.backend != nil {
+		return a.backend.modTime()
+	}
+	if info, err := a.fs().Stat(a.Path); err == nil {
+		mtime = info.ModTime()
 	}
 	return
 }
+
+func (a *AutoreloadingConfigStore[T]) watchedPaths() []string {
+	if a.backend != nil {
+		return a.backend.watchedPaths()
+	}
+	return watchedPathsForFile(nil, a.Path)
+}
+
+// watchedPathsForFile appends the paths to watch for changes to a file:
+// the parent directory (to catch rename(2) and symlink swaps), and the file
+// itself with symlinks resolved (to catch in-place writes to a symlink target
+// that lives in another directory).
+func watchedPathsForFile(paths []string, path string) []string {
+	paths = append(paths, filepath.Dir(path))
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		paths = append(paths, resolved)
+	}
+	return paths
+}
+
+func (a *AutoreloadingConfigStore[T]) watchDebounce() time.Duration {
+	if a.WatchDebounce != 0 {
+		return a.WatchDebounce
+	}
+	return 100 * time.Millisecond
+}
+
+// getFingerprint returns a string identifying the current state of the file,
+// according to the change detection strategy; if the file is inaccessible,
+// it returns the empty string.
+func getFingerprint(fsys ConfigFS, path string, detector ChangeDetector) string {
+	if detector == DetectContentHash {
+		data, err := fsys.ReadFile(path)
+		if err != nil {
+			return ""
+		}
+		sum := sha256.Sum256(data)
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return ""
+	}
+	mtime := info.ModTime().UnixNano()
+	if detector == DetectStat {
+		return fmt.Sprintf("stat:%d:%d:%d", info.Size(), mtime, getInode(info))
+	}
+	return fmt.Sprintf("mtime:%d", mtime)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"fmt"
+)
+
+// DerivedStore exposes a value derived from the value of an
+// AutoreloadingConfigStore; see Derive.
+type DerivedStore[U any] struct {
+	get func() *U
+}
+
+// Get returns the value derived from the store's current value
+// (or nil if the store has no current value). It is wait-free.
+func (d *DerivedStore[U]) Get() *U {
+	return d.get()
+}
+
+/*
+Derive registers a derivation on a store, returning a DerivedStore whose
+value is computed by `derive` exactly once per load, for example to compile
+regular expressions or build a *tls.Config from the config. The derived value
+is computed before the new config is stored, and is stored atomically with it
+(so that store.Get() and the derived Get() always observe the same version);
+if `derive` returns an error, the load fails, as if LoadCallback had returned
+the error.
+
+If the store already has a value, `derive` is applied to it immediately, and
+its error (if any) is returned, in which case the derivation is not registered.
+*/
+func Derive[T, U any](store *AutoreloadingConfigStore[T], derive func(*T) (*U, error)) (*DerivedStore[U], error) {
+	// prevent loads while we update the current version:
+	store.reloadMutex.Lock()
+	defer store.reloadMutex.Unlock()
+
+	derivation := func(value *T) (any, error) {
+		return derive(value)
+	}
+
+	current := store.current.Load()
+	var currentDerived any
+	if current != nil && current.Value != nil {
+		var err error
+		if currentDerived, err = derivation(current.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	store.stateMutex.Lock()
+	defer store.stateMutex.Unlock()
+	index := len(store.derivations)
+	store.derivations = append(store.derivations, derivation)
+	if current != nil {
+		// replace the current version with a copy that includes the new derived value
+		// (we cannot modify it in place, since readers may be accessing it):
+		updated := *current
+		updated.derived = append(append([]any(nil), current.derived...), currentDerived)
+		store.current.Store(&updated)
+		if n := len(store.history); n != 0 && store.history[n-1] == current {
+			store.history[n-1] = &updated
+		}
+	}
+
+	return &DerivedStore[U]{
+		get: func() *U {
+			if current := store.current.Load(); current != nil && index < len(current.derived) {
+				value, _ := current.derived[index].(*U)
+				return value
+			}
+			return nil
+		},
+	}, nil
+}
+
+// derive computes all the derived values for a newly loaded value;
+// the caller must hold reloadMutex.
+func (a *AutoreloadingConfigStore[T]) derive(value *T) (derived []any, err error) {
+	a.stateMutex.Lock()
+	derivations := a.derivations
+	a.stateMutex.Unlock()
+
+	if len(derivations) == 0 {
+		return nil, nil
+	}
+	derived = make([]any, len(derivations))
+	for i, derivation := range derivations {
+		if derived[i], err = derivation(value); err != nil {
+			return nil, fmt.Errorf("derivation failed: %w", err)
+		}
+	}
+	return
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrVersionNotFound = errors.New("config version not found in history")
+
+// ConfigStatus is a snapshot of the state of an AutoreloadingConfigStore,
+// suitable for exposing from a health endpoint.
+type ConfigStatus struct {
+	// Generation, Fingerprint, LoadedAt, and ModTime describe the current
+	// version (see ConfigVersion).
+	Generation  uint64
+	Fingerprint string
+	LoadedAt    time.Time
+	ModTime     time.Time
+	// LastSuccess is the time of the most recent successful load.
+	LastSuccess time.Time
+	// LastFailure and LastError describe the most recent failed load
+	// (including loads rejected by ValidateCallback or HealthCheck).
+	LastFailure time.Time
+	LastError   error
+	// ConsecutiveFailures is the number of failed loads since the last
+	// successful one.
+	ConsecutiveFailures int
+}
+
+// Status returns a snapshot of the store's current status.
+func (a *AutoreloadingConfigStore[T]) Status() (status ConfigStatus) {
+	a.stateMutex.Lock()
+	status = a.status
+	a.stateMutex.Unlock()
+
+	if current := a.current.Load(); current != nil {
+		status.Generation = current.Generation
+		status.Fingerprint = current.Fingerprint
+		status.LoadedAt = current.LoadedAt
+		status.ModTime = current.ModTime
+	}
+	return
+}
+
+// History returns the retained versions of the config, oldest first;
+// the last entry is the current version. The result of a failed initial
+// load is not included.
+func (a *AutoreloadingConfigStore[T]) History() []*ConfigVersion[T] {
+	a.stateMutex.Lock()
+	defer a.stateMutex.Unlock()
+	return append([]*ConfigVersion[T](nil), a.history...)
+}
+
+// Rollback restores the value from a previous version (identified by its
+// Generation), storing it as a new version and notifying subscribers.
+// The rolled-back value is kept until the file changes again.
+func (a *AutoreloadingConfigStore[T]) Rollback(generation uint64) (*T, error) {
+	a.reloadMutex.Lock()
+	defer a.reloadMutex.Unlock()
+
+	var target *ConfigVersion[T]
+	for _, version := range a.History() {
+		if version.Generation == generation {
+			target = version
+		}
+	}
+	current := a.current.Load()
+	if target == nil || target.Value == nil || current == nil {
+		return a.Get(), ErrVersionNotFound
+	}
+	// recompute derived values if derivations were added in the meantime:
+	derived := target.derived
+	if len(derived) != len(a.derivations) {
+		var err error
+		if derived, err = a.derive(target.Value); err != nil {
+			return current.Value, err
+		}
+	}
+	// keep the current fingerprint, so that ReloadIfChanged() doesn't
+	// immediately undo the rollback:
+	rollback := *target
+	rollback.Fingerprint = current.Fingerprint
+	rollback.derived = derived
+	a.commit(&rollback)
+	a.notify(ConfigChange[T]{Old: current.Value, New: target.Value})
+	return target.Value, nil
+}
+
+// commit stores a new version of the config (assigning its Generation and
+// LoadedAt) and records it in the history; the caller must hold reloadMutex.
+func (a *AutoreloadingConfigStore[T]) commit(version *ConfigVersion[T]) {
+	a.store(version, true)
+}
+
+// store is like commit, but records the version in the history only if
+// `record` is set (the result of a failed initial load is stored, but it
+// is not a valid version to roll back to).
+func (a *AutoreloadingConfigStore[T]) store(version *ConfigVersion[T], record bool) {
+	a.stateMutex.Lock()
+	defer a.stateMutex.Unlock()
+
+	a.generation++
+	version.Generation = a.generation
+	version.LoadedAt = a.clock().Now()
+	a.current.Store(version)
+	if !record {
+		return
+	}
+
+	historySize := a.HistorySize
+	if historySize <= 0 {
+		historySize = 8
+	}
+	a.history = append(a.history, version)
+	if len(a.history) > historySize {
+		// copy, so that the evicted versions can be garbage-collected:
+		a.history = append([]*ConfigVersion[T](nil), a.history[len(a.history)-historySize:]...)
+	}
+}
+
+// recordResult updates the status (and the metrics, if applicable)
+// with the outcome of a load.
+func (a *AutoreloadingConfigStore[T]) recordResult(err error, latency time.Duration) {
+	if a.Metrics != nil {
+		a.Metrics.ObserveLoad(latency, err)
+	}
+
+	a.stateMutex.Lock()
+	defer a.stateMutex.Unlock()
+
+	if err == nil {
+		a.status.LastSuccess = a.clock().Now()
+		a.status.ConsecutiveFailures = 0
+	} else {
+		a.status.LastFailure = a.clock().Now()
+		a.status.LastError = err
+		a.status.ConsecutiveFailures++
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ConfigLoader is a ready-made LoadCallback for AutoreloadingConfigStore.
+Loaders can be composed with the With* methods, so that a typical
+LoadCallback is a one-liner:
+
+	cfg = AutoreloadingConfigStore[Config]{
+		Path:          "./config.json",
+		LoadCallback:  StrictJSONLoader[Config]().WithEnvOverlay("MYAPP_").WithValidation(validateConfig),
+		CheckInterval: 10 * time.Second,
+	}
+
+Other formats (YAML, TOML) can be supported by passing the relevant
+Unmarshal function to UnmarshalLoader.
+*/
+type ConfigLoader[T any] func(path string) (*T, error)
+
+// ConfigDefaulter can be implemented by (a pointer to) a config type; if so,
+// SetDefaults is called on each new value before the file is decoded into it,
+// so that any fields absent from the file retain their defaults.
+type ConfigDefaulter interface {
+	SetDefaults()
+}
+
+// UnmarshalLoader returns a ConfigLoader that reads the file and decodes it
+// with `unmarshal`, which has the signature of json.Unmarshal.
+func UnmarshalLoader[T any](unmarshal func([]byte, any) error) ConfigLoader[T] {
+	return func(path string) (*T, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		value := newConfigValue[T]()
+		if err := unmarshal(data, value); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		return value, nil
+	}
+}
+
+// JSONLoader returns a ConfigLoader for JSON files.
+func JSONLoader[T any]() ConfigLoader[T] {
+	return UnmarshalLoader[T](json.Unmarshal)
+}
+
+// StrictJSONLoader returns a ConfigLoader for JSON files that rejects
+// fields not present in T, as well as trailing data after the JSON value.
+func StrictJSONLoader[T any]() ConfigLoader[T] {
+	return UnmarshalLoader[T](unmarshalJSONStrict)
+}
+
+func unmarshalJSONStrict(data []byte, value any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("trailing data after JSON value")
+	}
+	return nil
+}
+
+func newConfigValue[T any]() *T {
+	value := new(T)
+	if defaulter, ok := any(value).(ConfigDefaulter); ok {
+		defaulter.SetDefaults()
+	}
+	return value
+}
+
+// WithValidation returns a ConfigLoader that additionally checks each loaded
+// value with `validate`, failing the load if it returns an error.
+func (l ConfigLoader[T]) WithValidation(validate func(*T) error) ConfigLoader[T] {
+	return func(path string) (*T, error) {
+		value, err := l(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := validate(value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+}
+
+/*
+WithEnvOverlay returns a ConfigLoader that overrides fields of the loaded
+value with environment variables, as specified by `env` struct tags; the
+variable name is the tag value with `prefix` prepended. For example, with
+a prefix of "MYAPP_":
+
+	type Config struct {
+		ListenAddress string        `json:"listen-address" env:"LISTEN_ADDRESS"`
+		Timeout       time.Duration `json:"timeout" env:"TIMEOUT"`
+		Database      struct {
+			Password string `json:"password" env:"PASSWORD"`
+		} `json:"database" env:"DB_"`
+	}
+
+reads MYAPP_LISTEN_ADDRESS, MYAPP_TIMEOUT, and MYAPP_DB_PASSWORD. As in
+the example, the tag on a nested struct is appended to the prefix for its
+fields. Supported field types are strings, bools, integers, floats,
+time.Duration (in the syntax of time.ParseDuration), []string (from a
+comma-separated list), and implementations of encoding.TextUnmarshaler.
+Unset variables leave the corresponding fields unchanged.
+*/
+func (l ConfigLoader[T]) WithEnvOverlay(prefix string) ConfigLoader[T] {
+	return func(path string) (*T, error) {
+		value, err := l(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyEnvOverlay(reflect.ValueOf(value).Elem(), prefix); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func applyEnvOverlay(value reflect.Value, prefix string) error {
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		fieldType := value.Type().Field(i)
+		if !fieldType.IsExported() {
+			continue
+		}
+		tag, tagged := fieldType.Tag.Lookup("env")
+		if tag == "-" {
+			continue
+		}
+
+		// recurse into nested structs (and non-nil pointers to them),
+		// unless they are handled by TextUnmarshaler:
+		nested := field
+		if nested.Kind() == reflect.Pointer && !nested.IsNil() {
+			nested = nested.Elem()
+		}
+		if nested.Kind() == reflect.Struct && !nested.Addr().Type().Implements(textUnmarshalerType) {
+			if err := applyEnvOverlay(nested, prefix+tag); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !tagged {
+			continue
+		}
+		name := prefix + tag
+		envValue, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setFromString(field, envValue); err != nil {
+			return fmt.Errorf("invalid value for environment variable %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setFromString(field reflect.Value, str string) (err error) {
+	if field.Addr().Type().Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str))
+	}
+	if field.Type() == durationType {
+		duration, err := time.ParseDuration(str)
+		if err == nil {
+			field.SetInt(int64(duration))
+		}
+		return err
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(str)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(str); err == nil {
+			field.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(str, 0, field.Type().Bits()); err == nil {
+			field.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if u, err = strconv.ParseUint(str, 0, field.Type().Bits()); err == nil {
+			field.SetUint(u)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(str, field.Type().Bits()); err == nil {
+			field.SetFloat(f)
+		}
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+		var elems []string
+		if str != "" {
+			elems = strings.Split(str, ",")
+		}
+		field.Set(reflect.ValueOf(elems).Convert(field.Type()))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNotModified is returned by (ConfigSource).Fetch to indicate that the
+// data has not changed since the version passed in.
+var ErrNotModified = errors.New("config source not modified")
+
+// ConfigSource is a source of config data for AutoreloadingConfigStore,
+// as an alternative to a file on disk.
+type ConfigSource interface {
+	// Fetch retrieves the current data, together with an opaque version token
+	// identifying it. `version` is the token of the currently stored data
+	// (or the empty string if there is none, or a reload is being forced);
+	// if the data is unchanged from that version, Fetch may return
+	// ErrNotModified instead.
+	Fetch(ctx context.Context, version string) (data []byte, newVersion string, err error)
+}
+
+/*
+HTTPConfigSource fetches config data from an HTTP(S) URL, using conditional
+requests (with ETag and If-Modified-Since) to avoid retransmitting unchanged
+data. For example:
+
+	cfg = AutoreloadingConfigStore[Config]{
+		Source:         &HTTPConfigSource{URL: "https://config.example.com/app.json"},
+		DecodeCallback: decodeConfig,
+		CheckInterval:  time.Minute,
+	}
+*/
+type HTTPConfigSource struct {
+	URL string
+	// Client is the client to use; if nil, http.DefaultClient is used.
+	Client *http.Client
+	// Header contains additional headers to send (e.g., Authorization).
+	Header http.Header
+}
+
+func (h *HTTPConfigSource) Fetch(ctx context.Context, version string) (data []byte, newVersion string, err error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL, nil)
+	if err != nil {
+		return
+	}
+	for key, values := range h.Header {
+		request.Header[key] = values
+	}
+	// the version token is the ETag and the Last-Modified time:
+	if etag, lastModified, found := strings.Cut(version, "\n"); found {
+		if etag != "" && !strings.HasPrefix(etag, "sha256:") {
+			request.Header.Set("If-None-Match", etag)
+		}
+		if lastModified != "" {
+			request.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, "", ErrNotModified
+	default:
+		return nil, "", fmt.Errorf("unexpected HTTP status fetching %s: %s", h.URL, response.Status)
+	}
+	if data, err = io.ReadAll(response.Body); err != nil {
+		return nil, "", err
+	}
+	etag, lastModified := response.Header.Get("ETag"), response.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		// no validators; fall back to a content hash
+		sum := sha256.Sum256(data)
+		etag = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	newVersion = etag + "\n" + lastModified
+	if newVersion == version {
+		return nil, "", ErrNotModified
+	}
+	return data, newVersion, nil
+}
+
+// FSConfigSource reads config data from a file in an fs.FS (for example,
+// an embed.FS). Since fs.FS implementations may not provide meaningful
+// modification times, changes are detected with a hash of the contents.
+type FSConfigSource struct {
+	FS   fs.FS
+	Path string
+}
+
+func (f *FSConfigSource) Fetch(ctx context.Context, version string) (data []byte, newVersion string, err error) {
+	if data, err = fs.ReadFile(f.FS, f.Path); err != nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	newVersion = "sha256:" + hex.EncodeToString(sum[:])
+	if newVersion == version {
+		return nil, "", ErrNotModified
+	}
+	return
+}
+
+// MemoryConfigSource is an in-memory ConfigSource, mainly for testing;
+// the zero value is ready to use (and holds empty data).
+type MemoryConfigSource struct {
+	mutex   sync.Mutex
+	data    []byte
+	version uint64
+	err     error
+}
+
+// Set replaces the data held by the source.
+func (m *MemoryConfigSource) Set(data []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.data = data
+	m.err = nil
+	m.version++
+}
+
+// SetError causes subsequent fetches to fail with `err`, until the next Set().
+func (m *MemoryConfigSource) SetError(err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.err = err
+}
+
+func (m *MemoryConfigSource) Fetch(ctx context.Context, version string) (data []byte, newVersion string, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.err != nil {
+		return nil, "", m.err
+	}
+	newVersion = strconv.FormatUint(m.version, 10)
+	if newVersion == version {
+		return nil, "", ErrNotModified
+	}
+	return m.data, newVersion, nil
+}
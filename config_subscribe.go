@@ -0,0 +1,85 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+// ConfigChange describes the outcome of a load by an AutoreloadingConfigStore.
+type ConfigChange[T any] struct {
+	// Old is the value that was previously stored (nil for the initial load).
+	Old *T
+	// New is the value that is now stored.
+	New *T
+	// Err is the error from the load, if any. If the load failed after
+	// Initialize(), the stored value is unchanged, so Old and New are equal.
+	Err error
+}
+
+type configSubscriber[T any] struct {
+	id       uint64
+	callback func(ConfigChange[T])
+}
+
+/*
+Subscribe registers a callback to be invoked with the outcome of every load
+(successful or otherwise), including the initial load in Initialize() if the
+subscription precedes it. It returns a function that cancels the subscription.
+The guarantees are as follows:
+
+ 1. Callbacks run after the new value has been stored, so Get() will return
+    it (unless a subsequent reload has already replaced it)
+ 2. Callbacks run synchronously in the goroutine performing the load, one
+    at a time, in the order of subscription; changes are delivered in the
+    order the loads happened
+ 3. No callback will be invoked after Stop() returns
+
+Since loads are serialized, a slow callback will delay subsequent reloads.
+Callbacks must not call Reload(), ReloadIfChanged(), or Stop() on the same
+store, since this will deadlock; Get() is safe.
+*/
+func (a *AutoreloadingConfigStore[T]) Subscribe(callback func(ConfigChange[T])) (unsubscribe func()) {
+	a.stateMutex.Lock()
+	defer a.stateMutex.Unlock()
+	a.nextSubID++
+	id := a.nextSubID
+	a.subscribers = append(a.subscribers, configSubscriber[T]{id: id, callback: callback})
+	return func() {
+		a.unsubscribe(id)
+	}
+}
+
+// SubscribeChannel is like Subscribe, but delivers changes by sending them
+// on a channel. The send blocks, so the channel should be buffered and
+// drained promptly, or it will stall reloads.
+func (a *AutoreloadingConfigStore[T]) SubscribeChannel(ch chan<- ConfigChange[T]) (unsubscribe func()) {
+	return a.Subscribe(func(change ConfigChange[T]) {
+		ch <- change
+	})
+}
+
+func (a *AutoreloadingConfigStore[T]) unsubscribe(id uint64) {
+	a.stateMutex.Lock()
+	defer a.stateMutex.Unlock()
+	// copy-on-write, since notify() may be iterating over the old slice:
+	subscribers := make([]configSubscriber[T], 0, len(a.subscribers))
+	for _, sub := range a.subscribers {
+		if sub.id != id {
+			subscribers = append(subscribers, sub)
+		}
+	}
+	a.subscribers = subscribers
+}
+
+// notify delivers a change to the subscribers; the caller must hold reloadMutex.
+func (a *AutoreloadingConfigStore[T]) notify(change ConfigChange[T]) {
+	a.stateMutex.Lock()
+	stopped := a.stopped
+	subscribers := a.subscribers
+	a.stateMutex.Unlock()
+
+	if stopped {
+		return
+	}
+	for _, sub := range subscribers {
+		sub.callback(change)
+	}
+}
@@ -0,0 +1,656 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func loadString(path string) (*string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result := string(data)
+	return &result, nil
+}
+
+// replace the file with rename(2), as an atomic config deployment would
+func writeAtomic(t *testing.T, path, contents string) {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// poll for a condition that is satisfied asynchronously
+func waitFor(condition func() bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestConfigStoreWatch(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("inotify is only available on Linux")
+	}
+	path := filepath.Join(t.TempDir(), "config.txt")
+	writeAtomic(t, path, "a")
+
+	store := AutoreloadingConfigStore[string]{
+		Path:          path,
+		LoadCallback:  loadString,
+		Watch:         true,
+		WatchDebounce: 10 * time.Millisecond,
+	}
+	value, err := store.Initialize()
+	assertEqual(err, nil)
+	assertEqual(*value, "a")
+	defer store.Stop()
+
+	writeAtomic(t, path, "b")
+	assertEqual(waitFor(func() bool { return *store.Get() == "b" }), true)
+
+	// in-place write to the new inode:
+	if err := os.WriteFile(path, []byte("c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(waitFor(func() bool { return *store.Get() == "c" }), true)
+}
+
+func TestConfigStoreContentHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.txt")
+	writeAtomic(t, path, "a")
+	loads := 0
+	store := AutoreloadingConfigStore[string]{
+		Path: path,
+		LoadCallback: func(path string) (*string, error) {
+			loads++
+			return loadString(path)
+		},
+		ChangeDetector: DetectContentHash,
+	}
+	store.Initialize()
+	assertEqual(loads, 1)
+	fingerprint := store.Current().Fingerprint
+
+	// touch(1) doesn't trigger a reload:
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(path, later, later)
+	value, err := store.ReloadIfChanged()
+	assertEqual(*value, "a")
+	assertEqual(err, nil)
+	assertEqual(loads, 1)
+
+	// a change that preserves the mtime does:
+	writeAtomic(t, path, "b")
+	os.Chtimes(path, later, later)
+	value, err = store.ReloadIfChanged()
+	assertEqual(*value, "b")
+	assertEqual(loads, 2)
+	assertEqual(store.Current().Fingerprint != fingerprint, true)
+}
+
+func TestConfigStoreSubscribe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.txt")
+	writeAtomic(t, path, "a")
+	store := AutoreloadingConfigStore[string]{
+		Path:         path,
+		LoadCallback: loadString,
+	}
+	changes := make(chan ConfigChange[string], 16)
+	store.SubscribeChannel(changes)
+	var seen []string
+	unsubscribe := store.Subscribe(func(change ConfigChange[string]) {
+		if change.Err == nil {
+			seen = append(seen, *change.New)
+		}
+	})
+
+	store.Initialize()
+	change := <-changes
+	assertEqual(change.Old == nil, true)
+	assertEqual(*change.New, "a")
+
+	writeAtomic(t, path, "b")
+	store.Reload()
+	change = <-changes
+	assertEqual(*change.Old, "a")
+	assertEqual(*change.New, "b")
+	assertEqual(change.Err, nil)
+
+	os.Remove(path)
+	store.Reload()
+	change = <-changes
+	assertEqual(*change.New, "b")
+	assertEqual(change.Err != nil, true)
+
+	unsubscribe()
+	writeAtomic(t, path, "c")
+	store.Reload()
+	<-changes
+	assertEqual(seen, []string{"a", "b"})
+
+	store.Stop()
+	store.Reload()
+	assertEqual(len(changes), 0)
+}
+
+func TestMultiConfigStore(t *testing.T) {
+	dir := t.TempDir()
+	confd := filepath.Join(dir, "conf.d")
+	os.Mkdir(confd, 0755)
+	main := filepath.Join(dir, "main.txt")
+	writeAtomic(t, main, "main")
+	writeAtomic(t, filepath.Join(confd, "b.txt"), "b")
+
+	store := AutoreloadingMultiConfigStore[[]string]{
+		Paths: []string{main, confd},
+		LoadCallback: func(paths []string) (*[]string, error) {
+			var result []string
+			for _, path := range paths {
+				value, err := loadString(path)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, *value)
+			}
+			return &result, nil
+		},
+	}
+	value, err := store.Initialize()
+	assertEqual(err, nil)
+	assertEqual(*value, []string{"main", "b"})
+
+	value, _ = store.ReloadIfChanged()
+	assertEqual(*value, []string{"main", "b"})
+
+	// adding a drop-in (hidden files are ignored):
+	writeAtomic(t, filepath.Join(confd, "a.txt"), "a")
+	writeAtomic(t, filepath.Join(confd, ".swp"), "x")
+	value, _ = store.ReloadIfChanged()
+	assertEqual(*value, []string{"main", "a", "b"})
+
+	os.Remove(filepath.Join(confd, "b.txt"))
+	value, _ = store.ReloadIfChanged()
+	assertEqual(*value, []string{"main", "a"})
+
+	// a missing explicit file is passed to the callback, which fails:
+	os.Remove(main)
+	value, err = store.ReloadIfChanged()
+	assertEqual(err != nil, true)
+	assertEqual(*value, []string{"main", "a"})
+
+	// InitializeContext also loads the set of files:
+	writeAtomic(t, main, "main")
+	contextStore := AutoreloadingMultiConfigStore[[]string]{
+		Paths:        store.Paths,
+		LoadCallback: store.LoadCallback,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	value, err = contextStore.InitializeContext(ctx)
+	assertEqual(err, nil)
+	assertEqual(*value, []string{"main", "a"})
+}
+
+func TestConfigStoreValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.txt")
+	writeAtomic(t, path, "a")
+	store := AutoreloadingConfigStore[string]{
+		Path:         path,
+		LoadCallback: loadString,
+		// writes in quick succession may have the same mtime:
+		ChangeDetector: DetectContentHash,
+		ValidateCallback: func(old, new *string) error {
+			if *new == "restart" {
+				return errors.New("change requires a restart")
+			}
+			return nil
+		},
+		HealthCheck: func(ctx context.Context, value *string) error {
+			if *value == "unhealthy" {
+				return errors.New("unhealthy")
+			}
+			return nil
+		},
+	}
+	store.Initialize()
+
+	writeAtomic(t, path, "restart")
+	value, err := store.Reload()
+	assertEqual(*value, "a")
+	assertEqual(err.Error(), "change requires a restart")
+
+	writeAtomic(t, path, "unhealthy")
+	value, err = store.Reload()
+	assertEqual(*value, "a")
+	assertEqual(errors.Is(err, ErrConfigRolledBack), true)
+	assertEqual(*store.Get(), "a")
+	// the rejected file is not retried until it changes:
+	loaded, _ := store.ReloadIfChanged()
+	assertEqual(loaded, value)
+
+	writeAtomic(t, path, "b")
+	value, err = store.ReloadIfChanged()
+	assertEqual(*value, "b")
+	assertEqual(err, nil)
+}
+
+func TestConfigStoreHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.txt")
+	writeAtomic(t, path, "a")
+	store := AutoreloadingConfigStore[string]{
+		Path:         path,
+		LoadCallback: loadString,
+		HistorySize:  2,
+	}
+	store.Initialize()
+	writeAtomic(t, path, "b")
+	store.Reload()
+	writeAtomic(t, path, "c")
+	store.Reload()
+
+	history := store.History()
+	assertEqual(len(history), 2)
+	assertEqual(*history[0].Value, "b")
+	assertEqual(history[1].Generation, uint64(3))
+	assertEqual(history[1], store.Current())
+
+	os.Remove(path)
+	store.Reload()
+	store.Reload()
+	status := store.Status()
+	assertEqual(status.ConsecutiveFailures, 2)
+	assertEqual(status.Generation, uint64(3))
+	assertEqual(status.LastError != nil, true)
+	assertEqual(status.LastFailure.After(status.LastSuccess), true)
+
+	value, err := store.Rollback(history[0].Generation)
+	assertEqual(err, nil)
+	assertEqual(*value, "b")
+	assertEqual(store.Current().Generation, uint64(4))
+	_, err = store.Rollback(1)
+	assertEqual(err, ErrVersionNotFound)
+
+	// a failed initial load is not recorded in the history:
+	missing := AutoreloadingConfigStore[string]{
+		Path:         filepath.Join(t.TempDir(), "missing.txt"),
+		LoadCallback: loadString,
+	}
+	_, err = missing.Initialize()
+	assertEqual(err != nil, true)
+	assertEqual(len(missing.History()), 0)
+	_, err = missing.Rollback(missing.Current().Generation)
+	assertEqual(err, ErrVersionNotFound)
+}
+
+type testConfig struct {
+	Name    string        `json:"name" env:"NAME"`
+	Port    int           `json:"port" env:"PORT"`
+	Timeout time.Duration `json:"timeout" env:"TIMEOUT"`
+	Nested  struct {
+		Hosts []string `json:"hosts" env:"HOSTS"`
+	} `json:"nested" env:"NESTED_"`
+}
+
+func (c *testConfig) SetDefaults() {
+	c.Port = 6667
+}
+
+func TestConfigLoaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeAtomic(t, path, `{"name": "test", "nested": {"hosts": ["a"]}}`)
+
+	value, err := JSONLoader[testConfig]()(path)
+	assertEqual(err, nil)
+	assertEqual(value.Name, "test")
+	assertEqual(value.Port, 6667)
+	assertEqual(value.Nested.Hosts, []string{"a"})
+
+	t.Setenv("TEST_PORT", "6697")
+	t.Setenv("TEST_TIMEOUT", "1m")
+	t.Setenv("TEST_NESTED_HOSTS", "b,c")
+	value, err = JSONLoader[testConfig]().WithEnvOverlay("TEST_")(path)
+	assertEqual(err, nil)
+	assertEqual(value.Port, 6697)
+	assertEqual(value.Timeout, time.Minute)
+	assertEqual(value.Nested.Hosts, []string{"b", "c"})
+
+	t.Setenv("TEST_PORT", "x")
+	_, err = JSONLoader[testConfig]().WithEnvOverlay("TEST_")(path)
+	assertEqual(err != nil, true)
+
+	writeAtomic(t, path, `{"name": "test", "nmae": "typo"}`)
+	_, err = JSONLoader[testConfig]()(path)
+	assertEqual(err, nil)
+	_, err = StrictJSONLoader[testConfig]()(path)
+	assertEqual(err != nil, true)
+
+	errInvalid := errors.New("invalid")
+	_, err = JSONLoader[testConfig]().WithValidation(func(c *testConfig) error {
+		return errInvalid
+	})(path)
+	assertEqual(err, errInvalid)
+}
+
+func TestConfigStoreDerive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.txt")
+	writeAtomic(t, path, "a")
+	store := AutoreloadingConfigStore[string]{
+		Path:           path,
+		LoadCallback:   loadString,
+		ChangeDetector: DetectContentHash,
+	}
+	store.Initialize()
+
+	derivations := 0
+	upper, err := Derive(&store, func(value *string) (*string, error) {
+		derivations++
+		if *value == "" {
+			return nil, errors.New("empty config")
+		}
+		result := strings.ToUpper(*value)
+		return &result, nil
+	})
+	assertEqual(err, nil)
+	assertEqual(*upper.Get(), "A")
+	assertEqual(derivations, 1)
+
+	writeAtomic(t, path, "b")
+	store.ReloadIfChanged()
+	store.ReloadIfChanged()
+	assertEqual(*upper.Get(), "B")
+	assertEqual(derivations, 2)
+
+	// a failed derivation fails the reload:
+	writeAtomic(t, path, "")
+	value, err := store.ReloadIfChanged()
+	assertEqual(err != nil, true)
+	assertEqual(*value, "b")
+	assertEqual(*upper.Get(), "B")
+
+	// rollbacks restore the derived value without recomputing it:
+	writeAtomic(t, path, "c")
+	store.Reload()
+	assertEqual(*upper.Get(), "C")
+	store.Rollback(store.Current().Generation - 1)
+	assertEqual(*upper.Get(), "B")
+	assertEqual(derivations, 4)
+}
+
+func TestSignalReloader(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no SIGHUP on windows")
+	}
+	path := filepath.Join(t.TempDir(), "config.txt")
+	writeAtomic(t, path, "a")
+	store := AutoreloadingConfigStore[string]{
+		Path:           path,
+		LoadCallback:   loadString,
+		ChangeDetector: DetectContentHash,
+	}
+	store.Initialize()
+
+	results := make(chan error, 1)
+	reloader := NewSignalReloader(func(name string, err error) {
+		assertEqual(name, "config")
+		results <- err
+	}, syscall.SIGHUP)
+	defer reloader.Stop()
+	remove := reloader.Add("config", &store)
+
+	writeAtomic(t, path, "b")
+	process, _ := os.FindProcess(os.Getpid())
+	process.Signal(syscall.SIGHUP)
+	assertEqual(<-results, nil)
+	assertEqual(*store.Get(), "b")
+
+	os.Remove(path)
+	reloader.ReloadAll()
+	assertEqual(<-results != nil, true)
+
+	remove()
+	reloader.ReloadAll()
+	assertEqual(len(results), 0)
+}
+
+func TestConfigStoreBackoff(t *testing.T) {
+	store := AutoreloadingConfigStore[string]{
+		CheckInterval: time.Second,
+		MaxBackoff:    5 * time.Second,
+	}
+	assertEqual(store.nextCheckInterval(), time.Second)
+	store.recordResult(errors.New("failed"), 0)
+	assertEqual(store.nextCheckInterval(), 2*time.Second)
+	store.recordResult(errors.New("failed"), 0)
+	assertEqual(store.nextCheckInterval(), 4*time.Second)
+	store.recordResult(errors.New("failed"), 0)
+	assertEqual(store.nextCheckInterval(), 5*time.Second)
+	assertEqual(store.Status().ConsecutiveFailures, 3)
+	store.recordResult(nil, 0)
+	assertEqual(store.nextCheckInterval(), time.Second)
+
+	store.CheckJitter = 0.1
+	for i := 0; i < 100; i++ {
+		interval := store.nextCheckInterval()
+		assertEqual(900*time.Millisecond <= interval && interval <= 1100*time.Millisecond, true)
+	}
+}
+
+func TestConfigStoreContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.txt")
+	writeAtomic(t, path, "a")
+	hang := make(chan struct{})
+	defer close(hang)
+	store := AutoreloadingConfigStore[string]{
+		Path: path,
+		LoadCallback: func(path string) (*string, error) {
+			if value, err := loadString(path); err != nil || *value != "hang" {
+				return value, err
+			}
+			<-hang
+			return nil, errors.New("unreachable")
+		},
+		LoadTimeout:   10 * time.Millisecond,
+		CheckInterval: time.Hour,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	value, err := store.InitializeContext(ctx)
+	assertEqual(err, nil)
+	assertEqual(*value, "a")
+
+	// a hung load is abandoned:
+	writeAtomic(t, path, "hang")
+	value, err = store.Reload()
+	assertEqual(*value, "a")
+	assertEqual(errors.Is(err, context.DeadlineExceeded), true)
+
+	cancel()
+	assertEqual(waitFor(func() bool {
+		store.stateMutex.Lock()
+		defer store.stateMutex.Unlock()
+		return store.stopped
+	}), true)
+}
+
+func decodeString(data []byte) (*string, error) {
+	result := string(data)
+	return &result, nil
+}
+
+func TestConfigStoreMemorySource(t *testing.T) {
+	var source MemoryConfigSource
+	source.Set([]byte("a"))
+	store := AutoreloadingConfigStore[string]{
+		Source:         &source,
+		DecodeCallback: decodeString,
+	}
+	value, err := store.Initialize()
+	assertEqual(*value, "a")
+	assertEqual(err, nil)
+	generation := store.Current().Generation
+
+	value, _ = store.ReloadIfChanged()
+	assertEqual(*value, "a")
+	assertEqual(store.Current().Generation, generation)
+
+	source.Set([]byte("b"))
+	value, _ = store.ReloadIfChanged()
+	assertEqual(*value, "b")
+
+	errUnavailable := errors.New("unavailable")
+	source.SetError(errUnavailable)
+	value, err = store.ReloadIfChanged()
+	assertEqual(*value, "b")
+	assertEqual(err, errUnavailable)
+}
+
+func TestConfigStoreHTTPSource(t *testing.T) {
+	var mutex sync.Mutex
+	body, requests, notModified := "a", 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests++
+		etag := `"` + body + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	store := AutoreloadingConfigStore[string]{
+		Source:         &HTTPConfigSource{URL: server.URL},
+		DecodeCallback: decodeString,
+	}
+	value, err := store.Initialize()
+	assertEqual(err, nil)
+	assertEqual(*value, "a")
+
+	value, err = store.ReloadIfChanged()
+	assertEqual(*value, "a")
+	assertEqual(notModified, 1)
+
+	mutex.Lock()
+	body = "b"
+	mutex.Unlock()
+	value, err = store.ReloadIfChanged()
+	assertEqual(err, nil)
+	assertEqual(*value, "b")
+	assertEqual(requests, 3)
+
+	// a forced reload is unconditional:
+	store.Reload()
+	assertEqual(requests, 4)
+	assertEqual(notModified, 1)
+}
+
+func TestConfigStoreHarness(t *testing.T) {
+	store := AutoreloadingConfigStore[string]{
+		Path: "/config.txt",
+		DecodeCallback: func(data []byte) (*string, error) {
+			if len(data) == 0 {
+				return nil, errors.New("empty config")
+			}
+			return decodeString(data)
+		},
+		CheckInterval: time.Minute,
+		MaxBackoff:    time.Hour,
+	}
+	h := NewConfigStoreHarness(&store)
+	h.WriteFile([]byte("a"))
+	store.Initialize()
+	defer store.Stop()
+	assertEqual(len(h.TakeChanges()), 1)
+	assertEqual(store.Current().LoadedAt, h.Clock.Now())
+
+	h.Clock.Advance(time.Second)
+	h.WriteFile([]byte("b"))
+	assertEqual(len(h.Advance(58*time.Second)), 0)
+	changes := h.Advance(time.Second)
+	assertEqual(len(changes), 1)
+	assertEqual(*changes[0].New, "b")
+	// no further reloads while the file is unchanged:
+	assertEqual(len(h.Advance(10*time.Minute)), 0)
+
+	// failures back off exponentially: the failures at 1, 3, and 7 minutes
+	// from now are followed by a success at 15 minutes
+	h.WriteFile(nil)
+	assertEqual(len(h.Advance(time.Minute)), 1)
+	assertEqual(len(h.Advance(2*time.Minute)), 1)
+	assertEqual(store.Status().ConsecutiveFailures, 2)
+	assertEqual(len(h.Advance(4*time.Minute)), 1)
+	h.WriteFile([]byte("c"))
+	assertEqual(len(h.Advance(8*time.Minute-time.Second)), 0)
+	changes = h.Advance(time.Second)
+	assertEqual(len(changes), 1)
+	assertEqual(*changes[0].New, "c")
+	assertEqual(store.Status().ConsecutiveFailures, 0)
+
+	store.Stop()
+	assertEqual(h.Clock.PendingTimers(), 0)
+}
+
+func TestConfigStoreMetrics(t *testing.T) {
+	var metrics ReloadMetrics
+	metrics.Buckets = []float64{1, 10}
+	store := AutoreloadingConfigStore[string]{
+		Path:           "/config.txt",
+		DecodeCallback: decodeString,
+		Metrics:        &metrics,
+	}
+	h := NewConfigStoreHarness(&store)
+	store.Initialize()
+	h.Clock.Advance(time.Minute)
+	h.WriteFile([]byte("a"))
+	store.ReloadIfChanged()
+	h.Clock.Advance(time.Minute)
+
+	snapshot := metrics.Snapshot()
+	assertEqual(snapshot.Attempts, uint64(2))
+	assertEqual(snapshot.Failures, uint64(1))
+	assertEqual(snapshot.BucketCounts, []uint64{2, 2})
+
+	exporter := PrometheusExporter{Clock: h.Clock}
+	exporter.Register(`my "config"`, &store, &metrics)
+	var buf strings.Builder
+	exporter.WriteTo(&buf)
+	output := buf.String()
+	for _, line := range []string{
+		"# TYPE godgets_config_reload_attempts_total counter\n",
+		`godgets_config_reload_failures_total{store="my \"config\""} 1` + "\n",
+		`godgets_config_load_duration_seconds_bucket{store="my \"config\"",le="+Inf"} 2` + "\n",
+		`godgets_config_load_duration_seconds_count{store="my \"config\""} 2` + "\n",
+		`godgets_config_age_seconds{store="my \"config\""} 60` + "\n",
+		`godgets_config_mtime_timestamp_seconds{store="my \"config\""} 946684860` + "\n",
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("missing line %q in output:\n%s", line, output)
+		}
+	}
+}
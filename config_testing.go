@@ -0,0 +1,87 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+ConfigStoreHarness is a helper for deterministic tests of code that uses
+an AutoreloadingConfigStore. It replaces the store's clock and filesystem
+with a FakeClock and a MemFS, and records the changes the store delivers,
+so that a test can mutate the file, advance time, and assert exactly when
+reloads fire. For example:
+
+	store := AutoreloadingConfigStore[Config]{
+		Path:           "/config.json",
+		DecodeCallback: decodeConfig,
+		CheckInterval:  time.Minute,
+	}
+	h := NewConfigStoreHarness(&store)
+	h.WriteFile([]byte(`{"port": 6667}`))
+	store.Initialize()
+	h.TakeChanges() // discard the initial load
+
+	h.WriteFile([]byte(`{"port": 6697}`))
+	if changes := h.Advance(59 * time.Second); len(changes) != 0 {
+		t.Fatal("reloaded early")
+	}
+	if changes := h.Advance(time.Second); len(changes) != 1 {
+		t.Fatal("didn't reload")
+	}
+
+Since the MemFS is only consulted for change detection and by DecodeCallback,
+the store should use DecodeCallback rather than LoadCallback. The harness must
+be created before the store is initialized.
+*/
+type ConfigStoreHarness[T any] struct {
+	Clock *FakeClock
+	FS    *MemFS
+	Store *AutoreloadingConfigStore[T]
+
+	mutex   sync.Mutex
+	changes []ConfigChange[T]
+}
+
+// NewConfigStoreHarness installs a FakeClock and MemFS into the store.
+func NewConfigStoreHarness[T any](store *AutoreloadingConfigStore[T]) *ConfigStoreHarness[T] {
+	clock := NewFakeClock(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC))
+	h := &ConfigStoreHarness[T]{
+		Clock: clock,
+		FS:    NewMemFS(clock),
+		Store: store,
+	}
+	store.Clock = h.Clock
+	store.FS = h.FS
+	store.Subscribe(func(change ConfigChange[T]) {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		h.changes = append(h.changes, change)
+	})
+	return h
+}
+
+// WriteFile replaces the contents of the store's file, with an mtime
+// of the fake clock's current time.
+func (h *ConfigStoreHarness[T]) WriteFile(data []byte) {
+	h.FS.WriteFile(h.Store.Path, data)
+}
+
+// Advance advances the fake clock, returning the changes that the store
+// delivered while it was advancing.
+func (h *ConfigStoreHarness[T]) Advance(d time.Duration) []ConfigChange[T] {
+	h.Clock.Advance(d)
+	return h.TakeChanges()
+}
+
+// TakeChanges returns the changes delivered since the last call to
+// TakeChanges or Advance.
+func (h *ConfigStoreHarness[T]) TakeChanges() (changes []ConfigChange[T]) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	changes, h.changes = h.changes, nil
+	return
+}
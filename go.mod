@@ -2,4 +2,7 @@ module github.com/slingamn/godgets
 
 go 1.19
 
-require github.com/gorilla/websocket v1.5.0 // indirect
+require (
+	github.com/gorilla/websocket v1.5.0 // indirect
+	golang.org/x/crypto v0.17.0
+)
@@ -0,0 +1,15 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+//go:build !unix
+
+package godgets
+
+import (
+	"os"
+)
+
+// getInode returns the inode number of a file, or 0 if it is unavailable.
+func getInode(info os.FileInfo) uint64 {
+	return 0
+}
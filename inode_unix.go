@@ -0,0 +1,19 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+//go:build unix
+
+package godgets
+
+import (
+	"os"
+	"syscall"
+)
+
+// getInode returns the inode number of a file, or 0 if it is unavailable.
+func getInode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
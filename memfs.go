@@ -0,0 +1,112 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// ConfigFS is the subset of filesystem operations used by the reloading
+// stores, allowing the real filesystem to be replaced in tests (see MemFS).
+type ConfigFS interface {
+	Stat(name string) (fs.FileInfo, error)
+	ReadFile(name string) ([]byte, error)
+}
+
+type osFS struct{}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+// MemFS is an in-memory ConfigFS, in which files are modified explicitly
+// and modification times are taken from a Clock (typically a FakeClock).
+type MemFS struct {
+	clock Clock
+
+	mutex sync.Mutex
+	files map[string]*memFile
+}
+
+type memFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+// NewMemFS returns an empty MemFS that takes modification times from `clock`.
+func NewMemFS(clock Clock) *MemFS {
+	return &MemFS{
+		clock: clock,
+		files: make(map[string]*memFile),
+	}
+}
+
+// WriteFile creates or replaces a file, setting its mtime to the current time.
+func (m *MemFS) WriteFile(name string, data []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.files[name] = &memFile{
+		data:    append([]byte(nil), data...),
+		modTime: m.clock.Now(),
+	}
+}
+
+// Chtimes sets the mtime of a file, like os.Chtimes.
+func (m *MemFS) Chtimes(name string, mtime time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	file, ok := m.files[name]
+	if !ok {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrNotExist}
+	}
+	m.files[name] = &memFile{data: file.data, modTime: mtime}
+	return nil
+}
+
+// Remove deletes a file.
+func (m *MemFS) Remove(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.files, name)
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	file, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memFileInfo{name: path.Base(name), file: file}, nil
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	file, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), file.data...), nil
+}
+
+type memFileInfo struct {
+	name string
+	file *memFile
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return int64(len(i.file.data)) }
+func (i memFileInfo) Mode() fs.FileMode  { return 0644 }
+func (i memFileInfo) ModTime() time.Time { return i.file.modTime }
+func (i memFileInfo) IsDir() bool        { return false }
+func (i memFileInfo) Sys() any           { return nil }
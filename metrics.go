@@ -0,0 +1,274 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReloadObserver is notified of the outcome of each load by a store;
+// err is nil for a successful load.
+type ReloadObserver interface {
+	ObserveLoad(latency time.Duration, err error)
+}
+
+// DefaultLatencyBuckets are the default histogram buckets for load latency,
+// in seconds.
+var DefaultLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// ReloadMetrics is a ReloadObserver that accumulates counters and a latency
+// histogram; the zero value is ready to use, with DefaultLatencyBuckets.
+type ReloadMetrics struct {
+	// Buckets are the upper bounds of the latency histogram buckets, in
+	// seconds, in increasing order. They must not be modified after the
+	// first load is observed.
+	Buckets []float64
+
+	mutex        sync.Mutex
+	attempts     uint64
+	failures     uint64
+	bucketCounts []uint64
+	latencySum   float64
+}
+
+// ReloadMetricsSnapshot is a point-in-time copy of a ReloadMetrics.
+type ReloadMetricsSnapshot struct {
+	Attempts  uint64
+	Successes uint64
+	Failures  uint64
+	// Buckets and BucketCounts describe the latency histogram;
+	// the counts are cumulative, as in Prometheus.
+	Buckets      []float64
+	BucketCounts []uint64
+	// LatencySum is the total latency of all loads, in seconds.
+	LatencySum float64
+}
+
+func (m *ReloadMetrics) ObserveLoad(latency time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	buckets := m.buckets()
+	if m.bucketCounts == nil {
+		m.bucketCounts = make([]uint64, len(buckets))
+	}
+	m.attempts++
+	if err != nil {
+		m.failures++
+	}
+	seconds := latency.Seconds()
+	m.latencySum += seconds
+	for i, bound := range buckets {
+		if seconds <= bound {
+			m.bucketCounts[i]++
+		}
+	}
+}
+
+func (m *ReloadMetrics) buckets() []float64 {
+	if m.Buckets != nil {
+		return m.Buckets
+	}
+	return DefaultLatencyBuckets
+}
+
+// Snapshot returns a copy of the current values of the metrics.
+func (m *ReloadMetrics) Snapshot() ReloadMetricsSnapshot {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	buckets := m.buckets()
+	counts := make([]uint64, len(buckets))
+	copy(counts, m.bucketCounts)
+	return ReloadMetricsSnapshot{
+		Attempts:     m.attempts,
+		Successes:    m.attempts - m.failures,
+		Failures:     m.failures,
+		Buckets:      append([]float64(nil), buckets...),
+		BucketCounts: counts,
+		LatencySum:   m.latencySum,
+	}
+}
+
+// StatusReporter is implemented by AutoreloadingConfigStore (and the types
+// that embed it).
+type StatusReporter interface {
+	Status() ConfigStatus
+}
+
+/*
+PrometheusExporter renders the metrics and status of a set of stores in the
+Prometheus text exposition format, without depending on the Prometheus client
+libraries. Each store is identified by a `store` label. For example:
+
+	var configMetrics ReloadMetrics
+	cfg.Metrics = &configMetrics
+	// ... initialize cfg ...
+	var exporter PrometheusExporter
+	exporter.Register("config", &cfg, &configMetrics)
+	http.Handle("/metrics", &exporter)
+*/
+type PrometheusExporter struct {
+	// Prefix is prepended to the metric names; if it is empty,
+	// "godgets_config_" is used.
+	Prefix string
+	// Clock, if set, replaces the system clock for computing ages.
+	Clock Clock
+
+	mutex   sync.Mutex
+	entries []exporterEntry
+}
+
+type exporterEntry struct {
+	name    string
+	store   StatusReporter
+	metrics *ReloadMetrics
+}
+
+// Register adds a store to the exporter; `metrics` may be nil, in which case
+// only the store's status is exported.
+func (p *PrometheusExporter) Register(name string, store StatusReporter, metrics *ReloadMetrics) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.entries = append(p.entries, exporterEntry{name: name, store: store, metrics: metrics})
+}
+
+type promSample struct {
+	suffix string // for histograms: _bucket, _sum, or _count
+	labels string
+	value  float64
+}
+
+type promFamily struct {
+	name, kind, help string
+	samples          []promSample
+}
+
+// WriteTo writes the metrics for all the registered stores.
+func (p *PrometheusExporter) WriteTo(w io.Writer) (n int64, err error) {
+	p.mutex.Lock()
+	entries := p.entries
+	p.mutex.Unlock()
+
+	prefix := p.Prefix
+	if prefix == "" {
+		prefix = "godgets_config_"
+	}
+	var clock Clock = realClock{}
+	if p.Clock != nil {
+		clock = p.Clock
+	}
+	now := clock.Now()
+
+	attempts := promFamily{name: "reload_attempts_total", kind: "counter", help: "Number of attempted loads."}
+	successes := promFamily{name: "reload_successes_total", kind: "counter", help: "Number of successful loads."}
+	failures := promFamily{name: "reload_failures_total", kind: "counter", help: "Number of failed loads."}
+	latency := promFamily{name: "load_duration_seconds", kind: "histogram", help: "Latency of loads."}
+	consecutive := promFamily{name: "consecutive_failures", kind: "gauge", help: "Number of failed loads since the last successful load."}
+	lastSuccess := promFamily{name: "last_success_timestamp_seconds", kind: "gauge", help: "Time of the last successful load."}
+	lastFailure := promFamily{name: "last_failure_timestamp_seconds", kind: "gauge", help: "Time of the last failed load."}
+	generation := promFamily{name: "generation", kind: "gauge", help: "Generation of the current value."}
+	age := promFamily{name: "age_seconds", kind: "gauge", help: "Time since the current value was stored."}
+	mtime := promFamily{name: "mtime_timestamp_seconds", kind: "gauge", help: "Modification time of the file the current value was loaded from."}
+
+	for _, entry := range entries {
+		label := fmt.Sprintf(`store="%s"`, escapeLabelValue(entry.name))
+		status := entry.store.Status()
+		consecutive.add(label, float64(status.ConsecutiveFailures))
+		lastSuccess.addTime(label, status.LastSuccess)
+		lastFailure.addTime(label, status.LastFailure)
+		generation.add(label, float64(status.Generation))
+		if !status.LoadedAt.IsZero() {
+			age.add(label, now.Sub(status.LoadedAt).Seconds())
+		}
+		mtime.addTime(label, status.ModTime)
+
+		if entry.metrics == nil {
+			continue
+		}
+		snapshot := entry.metrics.Snapshot()
+		attempts.add(label, float64(snapshot.Attempts))
+		successes.add(label, float64(snapshot.Successes))
+		failures.add(label, float64(snapshot.Failures))
+		for i, bound := range snapshot.Buckets {
+			latency.samples = append(latency.samples, promSample{"_bucket", fmt.Sprintf(`%s,le="%s"`, label, formatPromValue(bound)), float64(snapshot.BucketCounts[i])})
+		}
+		latency.samples = append(latency.samples,
+			promSample{"_bucket", label + `,le="+Inf"`, float64(snapshot.Attempts)},
+			promSample{"_sum", label, snapshot.LatencySum},
+			promSample{"_count", label, float64(snapshot.Attempts)},
+		)
+	}
+
+	counter := &countingWriter{w: w}
+	bw := bufio.NewWriter(counter)
+	for _, family := range []promFamily{attempts, successes, failures, latency, consecutive, lastSuccess, lastFailure, generation, age, mtime} {
+		if len(family.samples) == 0 {
+			continue
+		}
+		name := prefix + family.name
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, family.help, name, family.kind)
+		for _, sample := range family.samples {
+			fmt.Fprintf(bw, "%s%s{%s} %s\n", name, sample.suffix, sample.labels, formatPromValue(sample.value))
+		}
+	}
+	err = bw.Flush()
+	return counter.n, err
+}
+
+// ServeHTTP serves the metrics, so that the exporter can be used as a
+// /metrics handler.
+func (p *PrometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+func (f *promFamily) add(labels string, value float64) {
+	f.samples = append(f.samples, promSample{labels: labels, value: value})
+}
+
+// addTime adds a timestamp sample, omitting the zero time (never happened)
+func (f *promFamily) addTime(labels string, t time.Time) {
+	if !t.IsZero() {
+		f.add(labels, float64(t.UnixNano())/1e9)
+	}
+}
+
+func formatPromValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.n += int64(n)
+	return
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*
+AutoreloadingMultiConfigStore is a variant of AutoreloadingConfigStore whose
+config is composed from a set of files, for example a main file plus a conf.d/
+directory of drop-ins. It reloads when any member of the set changes, or when
+members are added or removed; the full set is passed to LoadCallback, so the
+composed value is swapped in atomically.
+
+Example usage:
+
+	cfg = AutoreloadingMultiConfigStore[Config]{
+		Paths:        []string{"/etc/app/config.json", "/etc/app/conf.d/", "/run/secrets/app-*"},
+		LoadCallback: loadConfigFiles,
+		AutoreloadingConfigStore: AutoreloadingConfigStore[Config]{
+			CheckInterval: 10 * time.Second,
+		},
+	}
+	if _, err := cfg.Initialize(); err != nil {
+		log.Fatal(err)
+	}
+*/
+type AutoreloadingMultiConfigStore[T any] struct {
+	// Paths is a list of files, directories, and glob patterns (in the syntax
+	// of filepath.Match). A file is always a member of the set, even if it
+	// doesn't exist (so LoadCallback can report the error); a directory
+	// contributes all the files it contains (excluding hidden files,
+	// i.e. dotfiles), and a glob contributes all the files that match it.
+	Paths []string
+	// LoadCallback receives the current set of member files, in the order of
+	// Paths (with directory contents and glob matches sorted by name); its
+	// semantics are otherwise the same as for AutoreloadingConfigStore.
+	LoadCallback func(paths []string) (*T, error)
+
+	// Path, LoadCallback, and FS of the embedded store are ignored; the other
+	// configuration fields (CheckInterval, Watch, etc.) apply to the whole set.
+	AutoreloadingConfigStore[T]
+}
+
+// Initialize initializes the store; see (*AutoreloadingConfigStore).Initialize.
+func (a *AutoreloadingMultiConfigStore[T]) Initialize() (value *T, err error) {
+	return a.InitializeContext(context.Background())
+}
+
+// InitializeContext initializes the store; see
+// (*AutoreloadingConfigStore).InitializeContext.
+func (a *AutoreloadingMultiConfigStore[T]) InitializeContext(ctx context.Context) (value *T, err error) {
+	a.AutoreloadingConfigStore.backend = multiFileBackend[T]{a}
+	return a.AutoreloadingConfigStore.InitializeContext(ctx)
+}
+
+// Files returns the current set of member files.
+func (a *AutoreloadingMultiConfigStore[T]) Files() (files []string) {
+	for _, path := range a.Paths {
+		if isGlob(path) {
+			matches, _ := filepath.Glob(path)
+			for _, match := range matches {
+				if isRegularFile(match) {
+					files = append(files, match)
+				}
+			}
+		} else if info, err := os.Stat(path); err == nil && info.IsDir() {
+			entries, _ := os.ReadDir(path)
+			// ReadDir returns the entries sorted by name
+			for _, entry := range entries {
+				member := filepath.Join(path, entry.Name())
+				if !strings.HasPrefix(entry.Name(), ".") && isRegularFile(member) {
+					files = append(files, member)
+				}
+			}
+		} else {
+			files = append(files, path)
+		}
+	}
+	return
+}
+
+type multiFileBackend[T any] struct {
+	store *AutoreloadingMultiConfigStore[T]
+}
+
+// the fingerprint of the set is a hash over the members' names and fingerprints,
+// so it changes if any member changes, or if members are added or removed
+func (m multiFileBackend[T]) fingerprint(detector ChangeDetector) string {
+	hash := sha256.New()
+	for _, file := range m.store.Files() {
+		hash.Write([]byte(file))
+		hash.Write([]byte{0})
+		hash.Write([]byte(getFingerprint(osFS{}, file, detector)))
+		hash.Write([]byte{'\n'})
+	}
+	return "files:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+func (m multiFileBackend[T]) load(_ context.Context) (*T, error) {
+	return m.store.LoadCallback(m.store.Files())
+}
+
+func (m multiFileBackend[T]) watchedPaths() (paths []string) {
+	for _, path := range m.store.Paths {
+		if isGlob(path) {
+			// watch the directory containing the matches (to see additions);
+			// if the directory itself is a pattern, watch the existing matches
+			if dir := filepath.Dir(path); !isGlob(dir) {
+				paths = append(paths, dir)
+			} else if dirs, err := filepath.Glob(dir); err == nil {
+				paths = append(paths, dirs...)
+			}
+		} else if info, err := os.Stat(path); err == nil && info.IsDir() {
+			paths = append(paths, path)
+		}
+	}
+	for _, file := range m.store.Files() {
+		paths = watchedPathsForFile(paths, file)
+	}
+	return
+}
+
+func (m multiFileBackend[T]) modTime() (latest time.Time) {
+	for _, file := range m.store.Files() {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return
+}
+
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, `*?[`)
+}
+
+func isRegularFile(path string) bool {
+	// follow symlinks, e.g. in a Kubernetes ConfigMap or Secret volume:
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -4,7 +4,9 @@
 package godgets
 
 import (
+	"container/list"
 	"context"
+	"sync"
 	"time"
 )
 
@@ -69,3 +71,198 @@ func (semaphore Semaphore) AcquireWithContext(ctx context.Context) (acquired boo
 func (semaphore Semaphore) Release() {
 	<-semaphore
 }
+
+// WeightedSemaphore is a counting semaphore in which each acquire can take
+// multiple units of capacity at once (like golang.org/x/sync/semaphore).
+// Waiters are served in FIFO order: a large acquire at the head of the queue
+// blocks subsequent smaller ones, so that it cannot be starved by them.
+// An acquire of more than the entire capacity does not join the queue
+// (it would block it forever); TryAcquire fails immediately, and the other
+// acquires wait without blocking anyone else.
+// Acquiring the full capacity gives exclusive access (e.g., a writer lock,
+// where readers acquire a single unit).
+type WeightedSemaphore struct {
+	mutex    sync.Mutex
+	capacity int
+	used     int
+	waiters  list.List // of *weightedWaiter
+	// waiters for more than the capacity, which can never be served:
+	oversized list.List // of *weightedWaiter
+}
+
+type weightedWaiter struct {
+	n     int
+	ready chan empty // closed when the units have been acquired on the waiter's behalf
+	// the waiter's element in either waiters or oversized (SetCapacity
+	// may move it from one to the other):
+	element *list.Element
+}
+
+// NewWeightedSemaphore creates and initializes a weighted semaphore
+// with a given capacity.
+func NewWeightedSemaphore(capacity int) *WeightedSemaphore {
+	return &WeightedSemaphore{capacity: capacity}
+}
+
+// Acquire acquires `n` units of the semaphore, blocking if necessary.
+// If `n` exceeds the capacity, it blocks until the capacity is raised
+// (see SetCapacity).
+func (s *WeightedSemaphore) Acquire(n int) {
+	s.acquire(nil, n)
+}
+
+// TryAcquire tries to acquire `n` units of the semaphore, returning whether
+// the acquire was successful. It never blocks, and it fails if there are
+// waiters, even if there is sufficient capacity.
+func (s *WeightedSemaphore) TryAcquire(n int) (acquired bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.tryAcquire(n)
+}
+
+// AcquireWithTimeout tries to acquire `n` units of the semaphore, blocking
+// for a maximum of approximately `d` while waiting for them. It returns whether
+// the acquire was successful.
+func (s *WeightedSemaphore) AcquireWithTimeout(n int, timeout time.Duration) (acquired bool) {
+	if timeout <= 0 {
+		return s.TryAcquire(n)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return s.acquire(ctx.Done(), n)
+}
+
+// AcquireWithContext tries to acquire `n` units of the semaphore, blocking at
+// most until the context expires. It returns whether the acquire was successful.
+// Note that if the context is already expired, the acquire may succeed anyway.
+func (s *WeightedSemaphore) AcquireWithContext(ctx context.Context, n int) (acquired bool) {
+	return s.acquire(ctx.Done(), n)
+}
+
+// Release releases `n` units of the semaphore.
+func (s *WeightedSemaphore) Release(n int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.used -= n
+	if s.used < 0 {
+		panic("semaphore released more than it was acquired")
+	}
+	s.notifyWaiters()
+}
+
+/*
+SetCapacity changes the capacity of the semaphore. Raising it wakes waiters
+that now fit; lowering it below the amount currently acquired does not affect
+existing holders, but new acquires will block until enough units have been
+released to bring usage under the new capacity. Waiters for more than the
+new capacity stop blocking the queue until it is raised again. For example,
+to apply a limit from an AutoreloadingConfigStore:
+
+	limiter := godgets.NewWeightedSemaphore(config.Get().MaxConcurrency)
+	config.Subscribe(func(change godgets.ConfigChange[Config]) {
+		if change.Err == nil {
+			limiter.SetCapacity(change.New.MaxConcurrency)
+		}
+	})
+*/
+func (s *WeightedSemaphore) SetCapacity(capacity int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.capacity = capacity
+	// waiters that no longer fit must not block the queue:
+	for element := s.waiters.Front(); element != nil; {
+		next := element.Next()
+		if waiter := element.Value.(*weightedWaiter); waiter.n > capacity {
+			s.waiters.Remove(element)
+			waiter.element = s.oversized.PushBack(waiter)
+		}
+		element = next
+	}
+	// and oversized waiters that now fit join the back of the queue:
+	for element := s.oversized.Front(); element != nil; {
+		next := element.Next()
+		if waiter := element.Value.(*weightedWaiter); waiter.n <= capacity {
+			s.oversized.Remove(element)
+			waiter.element = s.waiters.PushBack(waiter)
+		}
+		element = next
+	}
+	s.notifyWaiters()
+}
+
+// Capacity returns the current capacity of the semaphore.
+func (s *WeightedSemaphore) Capacity() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.capacity
+}
+
+// tryAcquire acquires immediately if possible; the caller must hold the mutex.
+func (s *WeightedSemaphore) tryAcquire(n int) bool {
+	if s.capacity-s.used >= n && s.waiters.Len() == 0 {
+		s.used += n
+		return true
+	}
+	return false
+}
+
+// acquire waits for `n` units until they are acquired or `done` is closed
+// (a nil `done` means to wait indefinitely).
+func (s *WeightedSemaphore) acquire(done <-chan struct{}, n int) (acquired bool) {
+	s.mutex.Lock()
+	if s.tryAcquire(n) {
+		s.mutex.Unlock()
+		return true
+	}
+	waiter := &weightedWaiter{n: n, ready: make(chan empty)}
+	if n > s.capacity {
+		// don't block the queue behind a waiter that can never be served
+		waiter.element = s.oversized.PushBack(waiter)
+	} else {
+		waiter.element = s.waiters.PushBack(waiter)
+	}
+	s.mutex.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return true
+	case <-done:
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	select {
+	case <-waiter.ready:
+		// we acquired concurrently with the cancellation
+		return true
+	default:
+	}
+	isFront := s.waiters.Front() == waiter.element
+	// Remove is a no-op if the element is not in the list:
+	s.waiters.Remove(waiter.element)
+	s.oversized.Remove(waiter.element)
+	if isFront {
+		// we may have been blocking smaller waiters behind us:
+		s.notifyWaiters()
+	}
+	return false
+}
+
+// notifyWaiters acquires on behalf of waiters, in FIFO order, while there is
+// sufficient capacity; the caller must hold the mutex.
+func (s *WeightedSemaphore) notifyWaiters() {
+	for {
+		front := s.waiters.Front()
+		if front == nil {
+			return
+		}
+		waiter := front.Value.(*weightedWaiter)
+		if s.capacity-s.used < waiter.n {
+			// don't let later, smaller waiters overtake the head of the queue
+			return
+		}
+		s.used += waiter.n
+		s.waiters.Remove(front)
+		close(waiter.ready)
+	}
+}
@@ -4,6 +4,7 @@
 package godgets
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -48,3 +49,146 @@ func TestAcquireWithTimeout(t *testing.T) {
 	// we should acquire successfully after approximately 100 msec
 	assertEqual(sem.AcquireWithTimeout(1*time.Second), true)
 }
+
+func TestWeightedSemaphore(t *testing.T) {
+	sem := NewWeightedSemaphore(4)
+
+	assertEqual(sem.TryAcquire(3), true)
+	assertEqual(sem.TryAcquire(2), false)
+	assertEqual(sem.TryAcquire(1), true)
+	assertEqual(sem.AcquireWithTimeout(1, 10*time.Millisecond), false)
+	sem.Release(3)
+	assertEqual(sem.AcquireWithTimeout(2, 10*time.Millisecond), true)
+	sem.Release(2)
+	sem.Release(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assertEqual(sem.AcquireWithContext(ctx, 5), false)
+	// an expired context doesn't prevent an immediate acquire:
+	assertEqual(sem.AcquireWithContext(ctx, 4), true)
+	sem.Release(4)
+}
+
+func TestWeightedSemaphoreFairness(t *testing.T) {
+	sem := NewWeightedSemaphore(4)
+	sem.Acquire(1)
+
+	// a large acquire is queued:
+	large := make(chan bool)
+	go func() {
+		large <- sem.AcquireWithTimeout(4, time.Second)
+	}()
+	for {
+		sem.mutex.Lock()
+		waiting := sem.waiters.Len()
+		sem.mutex.Unlock()
+		if waiting != 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// smaller acquires can't overtake it, although there is capacity:
+	assertEqual(sem.TryAcquire(1), false)
+	small := make(chan bool)
+	go func() {
+		small <- sem.AcquireWithTimeout(1, time.Second)
+	}()
+
+	sem.Release(1)
+	assertEqual(<-large, true)
+	sem.Release(4)
+	assertEqual(<-small, true)
+	sem.Release(1)
+
+	// a waiter that times out at the head of the queue unblocks the ones behind it:
+	sem.Acquire(2)
+	go func() {
+		large <- sem.AcquireWithTimeout(4, 50*time.Millisecond)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	go func() {
+		small <- sem.AcquireWithTimeout(2, time.Second)
+	}()
+	assertEqual(<-large, false)
+	assertEqual(<-small, true)
+	sem.Release(2)
+	sem.Release(2)
+	assertEqual(sem.TryAcquire(4), true)
+}
+
+func TestWeightedSemaphoreOversized(t *testing.T) {
+	sem := NewWeightedSemaphore(2)
+	assertEqual(sem.TryAcquire(3), false)
+
+	// an acquire of more than the capacity doesn't block the queue:
+	oversized := make(chan bool)
+	go func() {
+		oversized <- sem.AcquireWithTimeout(3, 100*time.Millisecond)
+	}()
+	for {
+		sem.mutex.Lock()
+		waiting := sem.oversized.Len()
+		sem.mutex.Unlock()
+		if waiting != 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assertEqual(sem.TryAcquire(1), true)
+	assertEqual(sem.AcquireWithTimeout(1, 10*time.Millisecond), true)
+	assertEqual(<-oversized, false)
+	sem.Release(2)
+	assertEqual(sem.TryAcquire(2), true)
+	sem.Release(2)
+
+	// lowering the capacity below a queued waiter moves it out of the queue,
+	// and raising it again moves it back:
+	sem.SetCapacity(5)
+	assertEqual(sem.TryAcquire(2), true)
+	go func() {
+		oversized <- sem.AcquireWithTimeout(4, time.Second)
+	}()
+	for {
+		sem.mutex.Lock()
+		waiting := sem.waiters.Len()
+		sem.mutex.Unlock()
+		if waiting != 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assertEqual(sem.TryAcquire(1), false)
+	sem.SetCapacity(3)
+	assertEqual(sem.TryAcquire(1), true)
+	sem.SetCapacity(7)
+	assertEqual(<-oversized, true)
+	sem.Release(4)
+	sem.Release(2)
+	sem.Release(1)
+	assertEqual(sem.TryAcquire(7), true)
+}
+
+func TestWeightedSemaphoreSetCapacity(t *testing.T) {
+	sem := NewWeightedSemaphore(2)
+	assertEqual(sem.TryAcquire(2), true)
+
+	// raising the capacity wakes waiters:
+	acquired := make(chan bool)
+	go func() {
+		acquired <- sem.AcquireWithTimeout(3, time.Second)
+	}()
+	sem.SetCapacity(5)
+	assertEqual(<-acquired, true)
+	assertEqual(sem.Capacity(), 5)
+
+	// lowering the capacity takes effect as holders release:
+	sem.SetCapacity(3)
+	assertEqual(sem.TryAcquire(1), false)
+	sem.Release(2)
+	assertEqual(sem.TryAcquire(1), false)
+	sem.Release(3)
+	assertEqual(sem.TryAcquire(3), true)
+	assertEqual(sem.TryAcquire(1), false)
+	sem.Release(3)
+}
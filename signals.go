@@ -0,0 +1,141 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Reloadable is a store that can be reloaded on demand, regardless of the type
+// of its value; it is implemented by AutoreloadingConfigStore (and the types
+// that embed it, like AutoreloadingCertStore).
+type Reloadable interface {
+	Refresh() error
+}
+
+// Refresh is equivalent to Reload(), except that it returns only the error;
+// it implements Reloadable.
+func (a *AutoreloadingConfigStore[T]) Refresh() error {
+	_, err := a.Reload()
+	return err
+}
+
+/*
+SignalReloader reloads a set of stores whenever the process receives one of
+a set of signals (typically SIGHUP), reporting the outcome for each store.
+
+Example usage:
+
+	reloader := NewSignalReloader(nil, syscall.SIGHUP)
+	defer reloader.Stop()
+	reloader.Add("config", &cfg)
+	reloader.Add("certificate", &certStore)
+*/
+type SignalReloader struct {
+	callback func(name string, err error)
+
+	mutex     sync.Mutex
+	stores    []namedReloadable
+	nextID    uint64
+	signals   chan os.Signal
+	stopEvent Event
+	done      Event
+	stopOnce  sync.Once
+}
+
+type namedReloadable struct {
+	id    uint64
+	name  string
+	store Reloadable
+}
+
+// NewSignalReloader starts listening for `signals` (SIGHUP if none are
+// specified). `callback` is invoked with the outcome of each store's reload,
+// in the order the stores were added; if it is nil, failures are logged.
+func NewSignalReloader(callback func(name string, err error), signals ...os.Signal) *SignalReloader {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	if callback == nil {
+		callback = logReloadResult
+	}
+	s := &SignalReloader{
+		callback:  callback,
+		signals:   make(chan os.Signal, 1),
+		stopEvent: NewEvent(),
+		done:      NewEvent(),
+	}
+	signal.Notify(s.signals, signals...)
+	go s.run()
+	return s
+}
+
+func logReloadResult(name string, err error) {
+	if err != nil {
+		log.Printf("Failed to reload %s: %v\n", name, err)
+	}
+}
+
+func (s *SignalReloader) run() {
+	defer s.done.Done()
+	for {
+		select {
+		case <-s.signals:
+			s.ReloadAll()
+		case <-s.stopEvent:
+			return
+		}
+	}
+}
+
+// Add registers a store to be reloaded; `name` identifies it to the callback.
+// It returns a function that unregisters the store.
+func (s *SignalReloader) Add(name string, store Reloadable) (remove func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.nextID++
+	id := s.nextID
+	s.stores = append(s.stores, namedReloadable{id: id, name: name, store: store})
+	return func() {
+		s.remove(id)
+	}
+}
+
+func (s *SignalReloader) remove(id uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stores := make([]namedReloadable, 0, len(s.stores))
+	for _, entry := range s.stores {
+		if entry.id != id {
+			stores = append(stores, entry)
+		}
+	}
+	s.stores = stores
+}
+
+// ReloadAll synchronously reloads all the registered stores, as though
+// one of the signals had been received.
+func (s *SignalReloader) ReloadAll() {
+	s.mutex.Lock()
+	stores := s.stores
+	s.mutex.Unlock()
+
+	for _, entry := range stores {
+		s.callback(entry.name, entry.store.Refresh())
+	}
+}
+
+// Stop stops listening for the signals (restoring their default behavior, unless
+// they are being handled elsewhere), waiting for any in-progress reloads to complete.
+func (s *SignalReloader) Stop() {
+	s.stopOnce.Do(func() {
+		signal.Stop(s.signals)
+		s.stopEvent.Done()
+	})
+	s.done.Wait(0)
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+package godgets
+
+import (
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const inotifyMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE |
+	syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotifyWatcher invokes a callback (after a debounce interval) whenever there
+// is any filesystem event on a set of watched paths. It doesn't interpret the
+// events; the callback is expected to check for itself whether anything
+// it cares about has actually changed.
+type inotifyWatcher struct {
+	file     *os.File
+	fd       int
+	paths    func() []string
+	debounce time.Duration
+	callback func()
+
+	mutex  sync.Mutex
+	timer  *time.Timer
+	closed bool
+}
+
+// watchPaths starts watching the paths returned by `paths`. Since the paths
+// may be replaced (and watches on a deleted inode are dropped by the kernel),
+// `paths` is re-evaluated and the watches re-added after every event.
+func watchPaths(paths func() []string, debounce time.Duration, callback func()) (io.Closer, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// since the fd is nonblocking, this registers it with the runtime poller,
+	// so that Close() will interrupt a pending Read():
+	w := &inotifyWatcher{
+		file:     os.NewFile(uintptr(fd), "inotify"),
+		fd:       fd,
+		paths:    paths,
+		debounce: debounce,
+		callback: callback,
+	}
+	for _, path := range paths() {
+		if _, err := syscall.InotifyAddWatch(fd, path, inotifyMask); err != nil {
+			w.file.Close()
+			return nil, err
+		}
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *inotifyWatcher) run() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		if n == 0 {
+			continue
+		}
+		w.mutex.Lock()
+		if w.closed {
+			w.mutex.Unlock()
+			return
+		}
+		for _, path := range w.paths() {
+			// this is a no-op for an inode that is already being watched;
+			// errors (e.g., a temporarily nonexistent file) are ignored
+			syscall.InotifyAddWatch(w.fd, path, inotifyMask)
+		}
+		if w.timer == nil {
+			w.timer = time.AfterFunc(w.debounce, w.callback)
+		} else {
+			w.timer.Stop()
+			w.timer.Reset(w.debounce)
+		}
+		w.mutex.Unlock()
+	}
+}
+
+// Close stops watching; a pending debounced callback is cancelled.
+func (w *inotifyWatcher) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	return w.file.Close()
+}
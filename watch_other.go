@@ -0,0 +1,18 @@
+// Copyright (c) 2026 Shivaram Lingamneni
+// released under the 0BSD license
+
+//go:build !linux
+
+package godgets
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+var errWatchUnsupported = errors.New("filesystem watching is only supported on Linux")
+
+func watchPaths(paths func() []string, debounce time.Duration, callback func()) (io.Closer, error) {
+	return nil, errWatchUnsupported
+}